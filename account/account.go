@@ -1,34 +1,104 @@
 package account
 
 import (
+	crand "crypto/rand"
 	"errors"
 	"fmt"
 	"math/rand/v2"
-	"net/url"
+	"menedger_paroley/otp"
+	"strings"
 	"time"
 )
 
 type Account struct {
-	Name      string    `json:"name"`
-	Login     string    `json:"login"`
-	Password  string    `json:"password"`
-	URL       string    `json:"url"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        string     `json:"id"`
+	Type      ItemType   `json:"type"`
+	Name      string     `json:"name"`
+	Login     string     `json:"login,omitempty"`
+	Password  string     `json:"password,omitempty"`
+	URIs      []URI      `json:"uris,omitempty"`
+	URL       string     `json:"url,omitempty"` // устарело: адрес до появления URIs, переносится Migrate
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
+	Folder          string           `json:"folder,omitempty"`
+	Tags            []string         `json:"tags,omitempty"`
+	Notes           string           `json:"notes,omitempty"`
+	Fields          []CustomField    `json:"fields,omitempty"`
+	OTP             *otp.Key         `json:"otp,omitempty"`
+	PasswordHistory []PasswordRecord `json:"passwordHistory,omitempty"`
+
+	PasswordChangedAt *time.Time `json:"passwordChangedAt,omitempty"`
+	ExpiresAt         *time.Time `json:"expiresAt,omitempty"`  // сменить пароль не позже этой даты
+	MaxAgeDays        int        `json:"maxAgeDays,omitempty"` // 0 — как в настройках сейфа, NoMaxAge — без ограничения
+
+	Favorite   bool       `json:"favorite,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"` // когда последний раз копировали или просматривали
+	UseCount   int        `json:"useCount,omitempty"`
+
+	// Данные остальных видов записей; заполнено только поле своего вида
+	Card     *Card          `json:"card,omitempty"`
+	Identity *Identity      `json:"identity,omitempty"`
+	SSHKey   *SSHKey        `json:"sshKey,omitempty"`
+	API      *APICredential `json:"api,omitempty"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// PasswordRecord — прежний пароль и время, когда его заменили
+type PasswordRecord struct {
+	Password   string    `json:"password"`
+	ReplacedAt time.Time `json:"replacedAt"`
 }
 
+// MaxPasswordHistory — сколько прежних паролей хранится у аккаунта
+const MaxPasswordHistory = 10
+
 var LetterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func (acc Account) Output() {
-    fmt.Printf("Имя: %s\n", acc.Name)
-    fmt.Printf("Логин: %s\n", acc.Login)
-    fmt.Printf("Пароль: %s\n", maskPassword(acc.Password)) // ← маскировка
-    fmt.Printf("URL: %s\n", acc.URL)
+    fmt.Printf("ID: %s\n", acc.ID)
+    fmt.Printf("Вид: %s\n", acc.Kind())
+    if acc.Favorite {
+        fmt.Printf("Имя: ★ %s\n", acc.Name)
+    } else {
+        fmt.Printf("Имя: %s\n", acc.Name)
+    }
+    acc.outputData() // секреты маскируются
+    if acc.Folder != "" {
+        fmt.Printf("Папка: %s\n", acc.Folder)
+    }
+    if len(acc.Tags) > 0 {
+        fmt.Printf("Теги: %s\n", strings.Join(acc.Tags, ", "))
+    }
+    for _, f := range acc.Fields {
+        fmt.Printf("%s: %s\n", f.Name, f.Display())
+    }
+    if acc.OTP != nil {
+        fmt.Printf("2FA: %s\n", acc.OTP.Type)
+    }
+    if acc.Notes != "" {
+        fmt.Printf("Заметки: %s\n", acc.Notes)
+    }
+    for _, att := range acc.Attachments {
+        fmt.Printf("Вложение: %s (%s)\n", att.Name, FormatSize(att.Size))
+    }
+    if acc.ExpiresAt != nil {
+        fmt.Printf("Сменить до: %s\n", acc.ExpiresAt.Format("02.01.2006"))
+    }
+    if acc.MaxAgeDays > 0 {
+        fmt.Printf("Менять каждые %d дн.\n", acc.MaxAgeDays)
+    }
     fmt.Printf("Создан: %s\n", acc.CreatedAt.Format("02.01.2006"))
+    if acc.LastUsedAt != nil {
+        fmt.Printf("Использован: %d раз, последний — %s\n", acc.UseCount, acc.LastUsedAt.Format("02.01.2006 15:04"))
+    }
     fmt.Println("---")
 }
 
-func maskPassword(p string) string {
+// MaskPassword оставляет видимыми только два первых и два последних символа
+func MaskPassword(p string) string {
     if len(p) <= 4 {
         return "****"
     }
@@ -45,20 +115,23 @@ func (acc *Account) GeneratePassword(n int) {
 }
 
 func NewAccount(name, login, password, urlString string) (*Account, error) {
-	if login == "" {
-		return nil, errors.New("некорректный логин")
-	}
-	_, err := url.ParseRequestURI(urlString)
-	if err != nil {
-		return nil, errors.New("некорректный URL")
-	}
+	return NewLogin(name, login, password, []URI{{URL: urlString}})
+}
+
+// NewLogin создаёт логин сайта с несколькими адресами; пустой пароль генерируется
+func NewLogin(name, login, password string, uris []URI) (*Account, error) {
 	newAcc := &Account{
+		ID:        NewID(),
+		Type:      TypeLogin,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		Name:      name,
 		Login:     login,
 		Password:  password,
-		URL:       urlString,
+		URIs:      uris,
+	}
+	if err := newAcc.validate(); err != nil {
+		return nil, err
 	}
 	if password == "" {
 		newAcc.GeneratePassword(12)
@@ -66,4 +139,163 @@ func NewAccount(name, login, password, urlString string) (*Account, error) {
 	return newAcc, nil
 }
 
+// NewID возвращает случайный UUID версии 4
+func NewID() string {
+	var b [16]byte
+	crand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// AccountUpdate — изменения полей аккаунта; nil означает «не менять»
+type AccountUpdate struct {
+	Name     *string
+	Login    *string
+	Password *string
+	URIs     *[]URI // заменяет список адресов целиком
+	Notes    *string
+	Fields   *[]CustomField // заменяет список полей целиком
+	Folder   *string
+	Tags     *[]string // заменяет список тегов целиком
+	OTP      *otp.Key
+	ClearOTP bool // убрать 2FA
+
+	ExpiresAt      *time.Time
+	ClearExpiresAt bool // убрать срок
+	MaxAgeDays     *int
+	Favorite       *bool
+
+	Card     *Card
+	Identity *Identity
+	SSHKey   *SSHKey
+	API      *APICredential
+}
+
+// IsEmpty сообщает, что ни одно поле не меняется
+func (upd AccountUpdate) IsEmpty() bool {
+	return upd.Name == nil && upd.Login == nil && upd.Password == nil && upd.URIs == nil &&
+		upd.Notes == nil && upd.Fields == nil && upd.Folder == nil && upd.Tags == nil &&
+		upd.OTP == nil && !upd.ClearOTP &&
+		upd.Card == nil && upd.Identity == nil && upd.SSHKey == nil && upd.API == nil &&
+		upd.ExpiresAt == nil && !upd.ClearExpiresAt && upd.MaxAgeDays == nil &&
+		upd.Favorite == nil
+}
 
+// Apply меняет поля аккаунта с той же проверкой, что и NewAccount,
+// и обновляет UpdatedAt. При ошибке аккаунт не меняется.
+func (acc *Account) Apply(upd AccountUpdate) error {
+	next := *acc
+	if upd.Name != nil {
+		next.Name = *upd.Name
+	}
+	if upd.Login != nil {
+		next.Login = *upd.Login
+	}
+	if upd.Password != nil {
+		next.Password = *upd.Password
+	}
+	if upd.URIs != nil {
+		next.URIs = append([]URI(nil), (*upd.URIs)...)
+	}
+	if upd.Notes != nil {
+		next.Notes = *upd.Notes
+	}
+	if upd.Fields != nil {
+		next.Fields = append([]CustomField(nil), (*upd.Fields)...)
+	}
+	if upd.Folder != nil {
+		next.Folder = NormalizeFolder(*upd.Folder)
+	}
+	if upd.Tags != nil {
+		next.Tags = NormalizeTags(*upd.Tags)
+	}
+	if upd.ClearOTP {
+		next.OTP = nil
+	}
+	if upd.OTP != nil {
+		key := *upd.OTP
+		next.OTP = &key
+	}
+	if upd.Card != nil {
+		card := *upd.Card
+		next.Card = &card
+	}
+	if upd.Identity != nil {
+		id := *upd.Identity
+		next.Identity = &id
+	}
+	if upd.SSHKey != nil {
+		key := *upd.SSHKey
+		next.SSHKey = &key
+	}
+	if upd.API != nil {
+		cred := *upd.API
+		next.API = &cred
+	}
+	if upd.ClearExpiresAt {
+		next.ExpiresAt = nil
+	}
+	if upd.ExpiresAt != nil {
+		at := *upd.ExpiresAt
+		next.ExpiresAt = &at
+	}
+	if upd.MaxAgeDays != nil {
+		next.MaxAgeDays = *upd.MaxAgeDays
+	}
+	if upd.Favorite != nil {
+		next.Favorite = *upd.Favorite
+	}
+	if err := next.validate(); err != nil {
+		return err
+	}
+	next.UpdatedAt = time.Now()
+	if next.Password != acc.Password {
+		next.PasswordHistory = pushPassword(acc.PasswordHistory, acc.Password, next.UpdatedAt)
+		changed := next.UpdatedAt
+		next.PasswordChangedAt = &changed
+	}
+	*acc = next
+	return nil
+}
+
+// pushPassword добавляет прежний пароль в начало истории, отбрасывая самые старые
+func pushPassword(history []PasswordRecord, password string, at time.Time) []PasswordRecord {
+	if password == "" {
+		return history
+	}
+	next := make([]PasswordRecord, 0, min(len(history)+1, MaxPasswordHistory))
+	next = append(next, PasswordRecord{Password: password, ReplacedAt: at})
+	for _, rec := range history {
+		if len(next) == MaxPasswordHistory {
+			break
+		}
+		next = append(next, rec)
+	}
+	return next
+}
+
+func (acc *Account) validate() error {
+	if err := acc.validateData(); err != nil {
+		return err
+	}
+	if acc.MaxAgeDays < NoMaxAge {
+		return errors.New("некорректный срок смены пароля")
+	}
+	for _, u := range acc.URIs {
+		if err := u.validate(); err != nil {
+			return err
+		}
+	}
+	for _, f := range acc.Fields {
+		if err := f.validate(); err != nil {
+			return err
+		}
+	}
+	if acc.OTP != nil {
+		if err := acc.OTP.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
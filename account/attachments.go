@@ -0,0 +1,368 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"menedger_paroley/crypto"
+	"path/filepath"
+	"time"
+)
+
+// BlobStore — необязательное расширение Db: хранилище, которое умеет держать
+// вложения отдельными объектами рядом с сейфом. Если Db его не реализует,
+// вложения хранятся прямо в сейфе.
+type BlobStore interface {
+	ReadBlob(id string) ([]byte, error)
+	WriteBlob(id string, data []byte) error
+	DeleteBlob(id string) error
+}
+
+const (
+	// MaxAttachmentSize — наибольший размер одного вложения
+	MaxAttachmentSize = 10 << 20
+	// MaxInlineAttachmentSize — вложения крупнее хранятся отдельными
+	// объектами, если хранилище это умеет
+	MaxInlineAttachmentSize = 64 << 10
+	// MaxInlineTotal — сколько всего вложений может лежать в самом сейфе,
+	// чтобы он не разрастался и быстро сохранялся
+	MaxInlineTotal = 16 << 20
+)
+
+var (
+	ErrAttachmentTooLarge  = fmt.Errorf("вложение больше %d МиБ", MaxAttachmentSize>>20)
+	ErrInlineLimit         = fmt.Errorf("в сейфе уже %d МиБ вложений, а хранилище не поддерживает отдельные файлы", MaxInlineTotal>>20)
+	ErrAttachmentNotFound  = errors.New("вложение не найдено")
+	ErrAttachmentNameEmpty = errors.New("у вложения должно быть имя")
+)
+
+// Attachment — зашифрованный файл, прикреплённый к записи. Содержимое
+// шифруется собственным ключом вложения, а ключ хранится в сейфе.
+type Attachment struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Size    int       `json:"size"`
+	Key     []byte    `json:"key"`
+	Data    []byte    `json:"data,omitempty"` // шифротекст, если вложение хранится в сейфе
+	Blob    bool      `json:"blob,omitempty"` // шифротекст лежит в BlobStore под ID
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// FormatSize печатает размер в байтах, КиБ или МиБ
+func FormatSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f МиБ", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f КиБ", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d Б", n)
+	}
+}
+
+// AddAttachment шифрует data и прикрепляет к записи accID. Крупные файлы
+// уходят в BlobStore сразу, а запись о них появится в хранилище со
+// следующим Save.
+func (v *VaultWithDb) AddAttachment(accID, name string, data []byte) (Attachment, error) {
+	name = filepath.Base(name)
+	if name == "." || name == string(filepath.Separator) {
+		return Attachment{}, ErrAttachmentNameEmpty
+	}
+	if len(data) > MaxAttachmentSize {
+		return Attachment{}, ErrAttachmentTooLarge
+	}
+	if _, ok := v.Get(accID); !ok {
+		return Attachment{}, ErrNotFound
+	}
+
+	key, err := crypto.NewDataKey()
+	if err != nil {
+		return Attachment{}, err
+	}
+	att := Attachment{ID: NewID(), Name: name, Size: len(data), Key: key, AddedAt: time.Now()}
+	sealed, err := crypto.SealData(key, data, []byte(att.ID))
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	store, ok := v.Db.(BlobStore)
+	if ok && len(data) > MaxInlineAttachmentSize {
+		if err := store.WriteBlob(att.ID, sealed); err != nil {
+			return Attachment{}, fmt.Errorf("не удалось сохранить вложение: %w", err)
+		}
+		att.Blob = true
+	} else {
+		att.Data = sealed
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	if !att.Blob && v.inlineSize()+len(sealed) > MaxInlineTotal {
+		return Attachment{}, ErrInlineLimit
+	}
+	i := v.indexOf(accID)
+	if i < 0 {
+		v.dropBlobLocked(att)
+		return Attachment{}, ErrNotFound
+	}
+	acc := &v.Data.Accounts[i]
+	acc.Attachments = append(acc.Attachments, att)
+	acc.UpdatedAt = att.AddedAt
+	v.Data.UpdatedAt = att.AddedAt
+	return att, nil
+}
+
+// ReadAttachment возвращает расшифрованное содержимое вложения
+func (v *VaultWithDb) ReadAttachment(accID, attID string) ([]byte, error) {
+	acc, ok := v.Get(accID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	for _, att := range acc.Attachments {
+		if att.ID == attID {
+			return v.openAttachment(att)
+		}
+	}
+	return nil, ErrAttachmentNotFound
+}
+
+// RemoveAttachment открепляет вложение. Отдельный объект удаляется из
+// хранилища только после успешного Save, чтобы сбой записи не оставил
+// сейф со ссылкой на пропавший файл.
+func (v *VaultWithDb) RemoveAttachment(accID, attID string) error {
+	v.Lock()
+	defer v.Unlock()
+	i := v.indexOf(accID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	acc := &v.Data.Accounts[i]
+	for j, att := range acc.Attachments {
+		if att.ID != attID {
+			continue
+		}
+		acc.Attachments = append(acc.Attachments[:j:j], acc.Attachments[j+1:]...)
+		now := time.Now()
+		acc.UpdatedAt = now
+		v.Data.UpdatedAt = now
+		v.dropBlobLocked(att)
+		return nil
+	}
+	return ErrAttachmentNotFound
+}
+
+// Export возвращает сейф в JSON, в котором все вложения лежат внутри,
+// — для резервных копий, не зависящих от BlobStore
+func (v *VaultWithDb) Export() ([]byte, error) {
+	v.RLock()
+	data := v.Data
+	data.Accounts = append([]Account(nil), v.Data.Accounts...)
+	data.Trash = append([]Account(nil), v.Data.Trash...)
+	v.RUnlock()
+
+	for _, list := range [][]Account{data.Accounts, data.Trash} {
+		for i := range list {
+			if err := v.inlineBlobs(&list[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return json.MarshalIndent(&data, "", "  ")
+}
+
+// inlineBlobs заменяет у копии записи ссылки на BlobStore самим шифротекстом
+func (v *VaultWithDb) inlineBlobs(acc *Account) error {
+	if len(acc.Attachments) == 0 {
+		return nil
+	}
+	atts := append([]Attachment(nil), acc.Attachments...)
+	for i, att := range atts {
+		if !att.Blob {
+			continue
+		}
+		sealed, err := v.readBlob(att.ID)
+		if err != nil {
+			return fmt.Errorf("вложение %q: %w", att.Name, err)
+		}
+		atts[i].Data, atts[i].Blob = sealed, false
+	}
+	acc.Attachments = atts
+	return nil
+}
+
+func (v *VaultWithDb) openAttachment(att Attachment) ([]byte, error) {
+	sealed := att.Data
+	if att.Blob {
+		var err error
+		if sealed, err = v.readBlob(att.ID); err != nil {
+			return nil, err
+		}
+	}
+	data, err := crypto.OpenData(att.Key, sealed, []byte(att.ID))
+	if err != nil {
+		return nil, fmt.Errorf("вложение %q повреждено", att.Name)
+	}
+	return data, nil
+}
+
+func (v *VaultWithDb) readBlob(id string) ([]byte, error) {
+	store, ok := v.Db.(BlobStore)
+	if !ok {
+		return nil, errors.New("хранилище не поддерживает отдельные файлы вложений")
+	}
+	return store.ReadBlob(id)
+}
+
+// inlineSize — сколько байт вложений хранится в самом сейфе
+func (v *VaultWithDb) inlineSize() int {
+	total := 0
+	for _, list := range [][]Account{v.Data.Accounts, v.Data.Trash} {
+		for _, acc := range list {
+			for _, att := range acc.Attachments {
+				total += len(att.Data)
+			}
+		}
+	}
+	return total
+}
+
+// dropBlobLocked ставит отдельный объект вложения в очередь на удаление
+// после ближайшего успешного Save. Вызывается под v.Lock.
+func (v *VaultWithDb) dropBlobLocked(att Attachment) {
+	if att.Blob {
+		v.staleBlobs = append(v.staleBlobs, att.ID)
+	}
+}
+
+// dropBlobsLocked ставит в очередь на удаление все отдельные объекты записи
+func (v *VaultWithDb) dropBlobsLocked(acc Account) {
+	for _, att := range acc.Attachments {
+		v.dropBlobLocked(att)
+	}
+}
+
+// ReplaceAccounts заменяет список записей, например при восстановлении
+// из резервной копии. В копии все вложения лежат внутри, поэтому крупные
+// снова выносятся в BlobStore, как при AddAttachment. Записи корзины с теми
+// же ID, что у восстановленных, удаляются, чтобы ID не повторялись.
+// Отдельные объекты вложений, на которые больше никто не ссылается,
+// удаляются после Save.
+func (v *VaultWithDb) ReplaceAccounts(accounts []Account) error {
+	accounts = append([]Account(nil), accounts...)
+	written, err := v.externalizeBlobs(accounts)
+	if err != nil {
+		return err
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	restored := make(map[string]bool, len(accounts))
+	for _, acc := range accounts {
+		restored[acc.ID] = true
+	}
+	var trash, dropped []Account
+	for _, acc := range v.Data.Trash {
+		if restored[acc.ID] {
+			dropped = append(dropped, acc)
+		} else {
+			trash = append(trash, acc)
+		}
+	}
+
+	kept := map[string]bool{}
+	inline := 0
+	for _, list := range [][]Account{accounts, trash} {
+		for _, acc := range list {
+			for _, att := range acc.Attachments {
+				kept[att.ID] = true
+				inline += len(att.Data)
+			}
+		}
+	}
+	if inline > MaxInlineTotal {
+		v.staleBlobs = append(v.staleBlobs, written...)
+		return ErrInlineLimit
+	}
+	for _, list := range [][]Account{v.Data.Accounts, dropped} {
+		for _, acc := range list {
+			for _, att := range acc.Attachments {
+				if !kept[att.ID] {
+					v.dropBlobLocked(att)
+				}
+			}
+		}
+	}
+	// Объект, который ждал удаления, снова нужен восстановленной записи
+	stale := v.staleBlobs[:0]
+	for _, id := range v.staleBlobs {
+		if !kept[id] {
+			stale = append(stale, id)
+		}
+	}
+	v.staleBlobs = stale
+
+	v.Data.Accounts = accounts
+	v.Data.Trash = trash
+	v.search = nil
+	v.Data.UpdatedAt = time.Now()
+	return nil
+}
+
+// externalizeBlobs переносит крупные вложения записей в BlobStore и
+// возвращает ID созданных объектов. Если объект с таким ID уже лежит
+// в хранилище с тем же шифротекстом, он используется повторно.
+func (v *VaultWithDb) externalizeBlobs(accounts []Account) ([]string, error) {
+	store, ok := v.Db.(BlobStore)
+	if !ok {
+		return nil, nil
+	}
+	var written []string
+	for i := range accounts {
+		acc := &accounts[i]
+		var atts []Attachment
+		for j, att := range acc.Attachments {
+			if att.Blob || len(att.Data) <= MaxInlineAttachmentSize {
+				continue
+			}
+			existing, err := store.ReadBlob(att.ID)
+			if err != nil || !bytes.Equal(existing, att.Data) {
+				if err := store.WriteBlob(att.ID, att.Data); err != nil {
+					v.Lock()
+					v.staleBlobs = append(v.staleBlobs, written...)
+					v.Unlock()
+					return nil, fmt.Errorf("не удалось сохранить вложение %q: %w", att.Name, err)
+				}
+			}
+			// Удалять при откате можно только объекты, которых раньше не было
+			if err != nil {
+				written = append(written, att.ID)
+			}
+			if atts == nil {
+				atts = append([]Attachment(nil), acc.Attachments...)
+			}
+			atts[j].Data, atts[j].Blob = nil, true
+		}
+		if atts != nil {
+			acc.Attachments = atts
+		}
+	}
+	return written, nil
+}
+
+// deleteStaleBlobs удаляет первые n объектов из очереди; ошибки не
+// критичны — в худшем случае в хранилище останется ненужный файл
+func (v *VaultWithDb) deleteStaleBlobs(n int) {
+	store, ok := v.Db.(BlobStore)
+	v.Lock()
+	n = min(n, len(v.staleBlobs))
+	ids := v.staleBlobs[:n:n]
+	v.staleBlobs = v.staleBlobs[n:]
+	v.Unlock()
+	if !ok {
+		return
+	}
+	for _, id := range ids {
+		store.DeleteBlob(id)
+	}
+}
@@ -0,0 +1,117 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// blobDb — flakyDb, который умеет хранить вложения отдельными объектами
+type blobDb struct {
+	flakyDb
+	blobs map[string][]byte
+}
+
+func newBlobDb() *blobDb {
+	return &blobDb{blobs: map[string][]byte{}}
+}
+
+func (d *blobDb) ReadBlob(id string) ([]byte, error) {
+	data, ok := d.blobs[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return data, nil
+}
+
+func (d *blobDb) WriteBlob(id string, data []byte) error {
+	d.blobs[id] = data
+	return nil
+}
+
+func (d *blobDb) DeleteBlob(id string) error {
+	delete(d.blobs, id)
+	return nil
+}
+
+// Резервная копия хранит вложения внутри; при восстановлении крупные
+// снова должны лечь в BlobStore, а не раздувать сейф
+func TestReplaceAccountsMovesLargeAttachmentsToBlobs(t *testing.T) {
+	db := newBlobDb()
+	v := testVaultWithKey(t, db)
+	acc := Account{ID: NewID(), Type: TypeLogin, Name: "Банк"}
+	v.AddAccount(acc)
+	big := bytes.Repeat([]byte("коды восстановления "), MaxInlineAttachmentSize/10)
+	att, err := v.AddAttachment(acc.ID, "codes.txt", big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := v.AddAttachment(acc.ID, "pin.txt", []byte("1234"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !att.Blob || small.Blob {
+		t.Fatalf("Blob: крупное %v, мелкое %v", att.Blob, small.Blob)
+	}
+
+	exported, err := v.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backup Vault
+	if err := json.Unmarshal(exported, &backup); err != nil {
+		t.Fatal(err)
+	}
+
+	// Запись удалена навсегда: её объект ждёт удаления при Save
+	v.Delete(acc.ID)
+	v.Purge(acc.ID)
+	if err := v.ReplaceAccounts(backup.Accounts); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := v.Get(acc.ID)
+	for _, a := range restored.Attachments {
+		if wantBlob := a.ID == att.ID; a.Blob != wantBlob || (a.Data == nil) != wantBlob {
+			t.Errorf("%s: Blob = %v, в сейфе %d байт", a.Name, a.Blob, len(a.Data))
+		}
+	}
+	if _, ok := db.blobs[att.ID]; !ok {
+		t.Fatalf("объект вложения удалён, хотя восстановленная запись на него ссылается")
+	}
+	if data, err := v.ReadAttachment(acc.ID, att.ID); err != nil || !bytes.Equal(data, big) {
+		t.Fatalf("ReadAttachment: %v", err)
+	}
+
+	// В новое хранилище объект записывается заново
+	other := newBlobDb()
+	fresh := testVaultWithKey(t, other)
+	if err := fresh.ReplaceAccounts(backup.Accounts); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fresh.ReadAttachment(acc.ID, att.ID); err != nil || !bytes.Equal(data, big) {
+		t.Fatalf("ReadAttachment в новом хранилище: %v", err)
+	}
+}
+
+// Без BlobStore вложения остаются в сейфе, но не больше MaxInlineTotal
+func TestReplaceAccountsInlineLimit(t *testing.T) {
+	v := testVaultWithKey(t, &flakyDb{})
+	before := Account{ID: NewID(), Type: TypeLogin, Name: "Почта"}
+	v.AddAccount(before)
+
+	var accounts []Account
+	for i := 0; i*MaxAttachmentSize <= MaxInlineTotal; i++ {
+		accounts = append(accounts, Account{ID: NewID(), Type: TypeLogin, Name: "Архив",
+			Attachments: []Attachment{{ID: NewID(), Name: "archive.zip", Data: make([]byte, MaxAttachmentSize)}}})
+	}
+	if err := v.ReplaceAccounts(accounts); err != ErrInlineLimit {
+		t.Fatalf("ReplaceAccounts: %v, ожидалась ErrInlineLimit", err)
+	}
+	if _, ok := v.Get(before.ID); !ok || len(v.Data.Accounts) != 1 {
+		t.Fatalf("после отказа сейф изменился")
+	}
+}
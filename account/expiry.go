@@ -0,0 +1,111 @@
+package account
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultExpiryWarningDays — за сколько дней до срока запись попадает в отчёт
+const DefaultExpiryWarningDays = 14
+
+// NoMaxAge в MaxAgeDays отключает политику сейфа для записи
+const NoMaxAge = -1
+
+// ExpiryWarning возвращает, за сколько до срока предупреждать о смене пароля
+func (s VaultSettings) ExpiryWarning() time.Duration {
+	days := s.ExpiryWarningDays
+	if days <= 0 {
+		days = DefaultExpiryWarningDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// PasswordSetAt возвращает, когда был установлен текущий пароль. У записей
+// старых версий без PasswordChangedAt это время последней замены из истории
+// или время создания.
+func (acc Account) PasswordSetAt() time.Time {
+	switch {
+	case acc.PasswordChangedAt != nil:
+		return *acc.PasswordChangedAt
+	case len(acc.PasswordHistory) > 0:
+		return acc.PasswordHistory[0].ReplacedAt
+	case !acc.CreatedAt.IsZero():
+		return acc.CreatedAt
+	default:
+		return acc.UpdatedAt
+	}
+}
+
+// maxAge возвращает допустимый возраст пароля: свой у записи или политику
+// сейфа. 0 — без ограничения.
+func (acc Account) maxAge(s VaultSettings) time.Duration {
+	days := acc.MaxAgeDays
+	if days == 0 {
+		days = s.PasswordMaxAgeDays
+	}
+	if days <= 0 || acc.Password == "" {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DueAt возвращает ближайший срок записи: явную дату ExpiresAt, окончание
+// допустимого возраста пароля или конец срока действия карты
+func (acc Account) DueAt(s VaultSettings) (time.Time, bool) {
+	var due time.Time
+	earlier := func(t time.Time) {
+		if due.IsZero() || t.Before(due) {
+			due = t
+		}
+	}
+	if acc.ExpiresAt != nil {
+		earlier(*acc.ExpiresAt)
+	}
+	if age := acc.maxAge(s); age > 0 {
+		earlier(acc.PasswordSetAt().Add(age))
+	}
+	if acc.Card != nil && acc.Card.Expiry != "" {
+		if t, err := time.Parse(cardExpiryLayout, acc.Card.Expiry); err == nil {
+			// Карта действует до последнего дня указанного месяца
+			earlier(t.AddDate(0, 1, -1))
+		}
+	}
+	return due, !due.IsZero()
+}
+
+// ExpiryEntry — строка отчёта о сроках
+type ExpiryEntry struct {
+	Account Account
+	DueAt   time.Time
+	Expired bool
+}
+
+// ExpiryReport возвращает просроченные записи и те, срок которых наступит
+// в пределах ExpiryWarning, — от самых давних к самым поздним
+func (v *VaultWithDb) ExpiryReport(now time.Time) []ExpiryEntry {
+	v.RLock()
+	defer v.RUnlock()
+	warn := v.Data.Settings.ExpiryWarning()
+	var report []ExpiryEntry
+	for _, acc := range v.Data.Accounts {
+		due, ok := acc.DueAt(v.Data.Settings)
+		if !ok || due.Sub(now) > warn {
+			continue
+		}
+		report = append(report, ExpiryEntry{Account: acc, DueAt: due, Expired: !due.After(now)})
+	}
+	slices.SortFunc(report, func(a, b ExpiryEntry) int {
+		return a.DueAt.Compare(b.DueAt)
+	})
+	return report
+}
+
+// SetPasswordPolicy задаёт допустимый возраст паролей по умолчанию
+// (0 — без ограничения) и срок предупреждения (0 — по умолчанию)
+func (v *VaultWithDb) SetPasswordPolicy(maxAgeDays, warningDays int) {
+	v.Lock()
+	defer v.Unlock()
+	v.Data.Settings.PasswordMaxAgeDays = max(maxAgeDays, 0)
+	v.Data.Settings.ExpiryWarningDays = max(warningDays, 0)
+	v.Data.UpdatedAt = time.Now()
+}
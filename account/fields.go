@@ -0,0 +1,76 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+)
+
+// FieldType — тип пользовательского поля
+type FieldType string
+
+const (
+	FieldText   FieldType = "text"
+	FieldHidden FieldType = "hidden" // секрет: маскируется при выводе и не участвует в поиске
+	FieldURL    FieldType = "url"
+	FieldEmail  FieldType = "email"
+)
+
+// FieldTypes — все типы полей в порядке показа в меню
+var FieldTypes = []FieldType{FieldText, FieldHidden, FieldURL, FieldEmail}
+
+func (t FieldType) String() string {
+	switch t {
+	case FieldText:
+		return "текст"
+	case FieldHidden:
+		return "скрытое"
+	case FieldURL:
+		return "URL"
+	case FieldEmail:
+		return "email"
+	default:
+		return string(t)
+	}
+}
+
+// CustomField — произвольное поле аккаунта: контрольный вопрос, PIN, API-ключ
+type CustomField struct {
+	Name  string    `json:"name"`
+	Value string    `json:"value"`
+	Type  FieldType `json:"type"`
+}
+
+func (f CustomField) validate() error {
+	if f.Name == "" {
+		return errors.New("у поля должно быть имя")
+	}
+	switch f.Type {
+	case FieldText, FieldHidden:
+	case FieldURL:
+		if _, err := url.ParseRequestURI(f.Value); err != nil {
+			return fmt.Errorf("поле %q: некорректный URL", f.Name)
+		}
+	case FieldEmail:
+		if _, err := mail.ParseAddress(f.Value); err != nil {
+			return fmt.Errorf("поле %q: некорректный email", f.Name)
+		}
+	default:
+		return fmt.Errorf("поле %q: неизвестный тип %q", f.Name, f.Type)
+	}
+	return nil
+}
+
+// Display возвращает значение для вывода; скрытые поля маскируются
+func (f CustomField) Display() string {
+	if f.Type == FieldHidden {
+		return MaskPassword(f.Value)
+	}
+	return f.Value
+}
+
+// Searchable сообщает, можно ли искать по значению поля
+func (f CustomField) Searchable() bool {
+	return f.Type != FieldHidden
+}
@@ -0,0 +1,490 @@
+package account
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchIndex — индекс открытых полей записей для Query.
+//
+// Для подстрок хранятся триграммы: для каждой тройки подряд идущих
+// символов — список документов, где она встречается. Кандидаты для слова —
+// пересечение списков его триграмм. Для опечаток слова всех документов
+// хранятся в префиксном дереве. Оценивать по полям приходится только
+// кандидатов.
+//
+// Документ — снимок записи: при изменении запись получает новый номер,
+// а старый помечается устаревшим и отсеивается при поиске. Когда
+// устаревших документов больше, чем живых, индекс строится заново.
+type searchIndex struct {
+	grams map[uint64][]uint32 // триграмма → номера документов по возрастанию
+	trie  trieNode            // слова всех документов
+	ids   []string            // номер документа → ID записи; "" — документ устарел
+	texts [][]loweredField    // номер документа → openFields записи
+	docOf map[string]uint32   // ID записи → её документ
+	pos   map[string]int      // ID записи → место в Vault.Accounts
+	dead  int
+}
+
+func newSearchIndex(accounts []Account) *searchIndex {
+	x := &searchIndex{
+		grams: make(map[uint64][]uint32),
+		docOf: make(map[string]uint32, len(accounts)),
+		pos:   make(map[string]int, len(accounts)),
+	}
+	for i, acc := range accounts {
+		x.add(acc, i)
+	}
+	return x
+}
+
+// add индексирует запись, стоящую на месте i
+func (x *searchIndex) add(acc Account, i int) {
+	doc := uint32(len(x.ids))
+	fields := openFields(acc)
+	x.ids = append(x.ids, acc.ID)
+	x.texts = append(x.texts, fields)
+	x.docOf[acc.ID] = doc
+	x.pos[acc.ID] = i
+
+	for _, f := range fields {
+		// Документ добавляется целиком, поэтому повтор виден по концу списка
+		var a, b rune
+		for j, c := range []rune(f.text) {
+			if j >= 2 {
+				g := uint64(a)<<42 | uint64(b)<<21 | uint64(c)
+				if list := x.grams[g]; len(list) == 0 || list[len(list)-1] != doc {
+					x.grams[g] = append(list, doc)
+				}
+			}
+			a, b = b, c
+		}
+		for _, w := range splitWords(f.text) {
+			x.trie.insert([]rune(w), doc)
+		}
+	}
+}
+
+// remove помечает документ записи устаревшим
+func (x *searchIndex) remove(id string) {
+	doc, ok := x.docOf[id]
+	if !ok {
+		return
+	}
+	x.ids[doc], x.texts[doc] = "", nil
+	delete(x.docOf, id)
+	delete(x.pos, id)
+	x.dead++
+}
+
+// stale сообщает, что индекс пора перестроить
+func (x *searchIndex) stale() bool {
+	return x.dead > len(x.docOf)
+}
+
+// lookup возвращает живые документы, в открытых полях которых есть
+// подстрока q (в нижнем регистре)
+func (x *searchIndex) lookup(q string) []uint32 {
+	var docs []uint32
+	grams := trigrams(q)
+	if len(grams) == 0 {
+		// У слов короче трёх символов триграмм нет — просматриваются тексты
+		for doc := range x.texts {
+			if x.contains(uint32(doc), q) {
+				docs = append(docs, uint32(doc))
+			}
+		}
+		return docs
+	}
+
+	lists := make([][]uint32, len(grams))
+	for i, g := range grams {
+		if lists[i] = x.grams[g]; len(lists[i]) == 0 {
+			return nil
+		}
+	}
+	slices.SortFunc(lists, func(a, b []uint32) int { return len(a) - len(b) })
+	candidates := lists[0]
+	for _, list := range lists[1:] {
+		if candidates = intersectDocs(candidates, list); len(candidates) == 0 {
+			return nil
+		}
+	}
+	// Триграммы могут встретиться в тексте вразнобой — подстрока проверяется
+	for _, doc := range candidates {
+		if x.contains(doc, q) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// contains сообщает, что в одном из полей документа есть подстрока q;
+// у устаревших документов полей нет
+func (x *searchIndex) contains(doc uint32, q string) bool {
+	for _, f := range x.texts[doc] {
+		if strings.Contains(f.text, q) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldsOf возвращает открытые поля записи из индекса, а без индекса
+// собирает их заново
+func (x *searchIndex) fieldsOf(acc *Account) []loweredField {
+	if x != nil {
+		if doc, ok := x.docOf[acc.ID]; ok {
+			return x.texts[doc]
+		}
+	}
+	return openFields(*acc)
+}
+
+// lookupFuzzy возвращает живые документы со словами, которые отличаются
+// от q не больше чем на limit правок целиком или своим началом, — те же,
+// что находит fuzzyWords. Словарь обходится в глубину со строками
+// расстояний; ветки, где все расстояния больше limit, не просматриваются.
+func (x *searchIndex) lookupFuzzy(q string, limit int) []uint32 {
+	f := fuzzyWalk{q: []rune(q), limit: limit}
+	row := make([]int, len(f.q)+1)
+	for j := range row {
+		row[j] = j
+	}
+	f.rows = [][]int{row}
+	for _, child := range x.trie.children {
+		f.visit(child)
+	}
+	slices.Sort(f.docs)
+	docs := slices.Compact(f.docs)
+	return slices.DeleteFunc(docs, func(doc uint32) bool { return x.ids[doc] == "" })
+}
+
+// trieNode — узел сжатого префиксного дерева слов
+type trieNode struct {
+	label    []rune      // буквы ребра от родителя
+	children []*trieNode // по первой букве label
+	docs     []uint32    // документы со словом, которое кончается здесь
+	longest  int         // длина самого длинного слова поддерева
+}
+
+func (t *trieNode) insert(word []rune, doc uint32) {
+	n, size := t, len(word)
+	for len(word) > 0 {
+		n.longest = max(n.longest, size)
+		k := n.childIndex(word[0])
+		if k == len(n.children) || n.children[k].label[0] != word[0] {
+			leaf := &trieNode{label: word, docs: []uint32{doc}, longest: size}
+			n.children = slices.Insert(n.children, k, leaf)
+			return
+		}
+		child := n.children[k]
+		p := commonPrefix(child.label, word)
+		if p < len(child.label) {
+			// Слово расходится с ребром посередине — ребро делится
+			tail := &trieNode{label: child.label[p:], children: child.children, docs: child.docs, longest: child.longest}
+			child.label = child.label[:p:p]
+			child.children, child.docs = []*trieNode{tail}, nil
+		}
+		n, word = child, word[p:]
+	}
+	n.longest = max(n.longest, size)
+	if len(n.docs) == 0 || n.docs[len(n.docs)-1] != doc {
+		n.docs = append(n.docs, doc)
+	}
+}
+
+// childIndex возвращает место ребра, начинающегося с r, среди детей
+func (t *trieNode) childIndex(r rune) int {
+	lo, hi := 0, len(t.children)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if t.children[mid].label[0] < r {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// collect добавляет документы всех слов поддерева
+func (t *trieNode) collect(docs []uint32) []uint32 {
+	docs = append(docs, t.docs...)
+	for _, child := range t.children {
+		docs = child.collect(docs)
+	}
+	return docs
+}
+
+// fuzzyWalk — состояние обхода словаря в lookupFuzzy
+type fuzzyWalk struct {
+	q     []rune
+	limit int
+	path  []rune  // буквы от корня до текущего места
+	rows  [][]int // rows[d] — расстояния между path[:d] и началами q
+	docs  []uint32
+}
+
+func (f *fuzzyWalk) visit(n *trieNode) {
+	depth := len(f.path)
+	if f.follow(n) {
+		for _, child := range n.children {
+			f.visit(child)
+		}
+	}
+	f.path = f.path[:depth]
+}
+
+// follow проходит ребро узла и собирает подходящие слова; false —
+// спускаться дальше не нужно
+func (f *fuzzyWalk) follow(n *trieNode) bool {
+	m := len(f.q)
+	if n.longest < m-f.limit {
+		// Все слова поддерева слишком короткие
+		return false
+	}
+	for _, r := range n.label {
+		f.path = append(f.path, r)
+		row, best := f.nextRow()
+		if len(f.path) == m && row[m] <= f.limit {
+			// Начало совпало с q — подходят все слова с этим началом
+			f.docs = n.collect(f.docs)
+			return false
+		}
+		if best > f.limit {
+			return false
+		}
+	}
+	if d := len(f.path); abs(d-m) <= f.limit && f.rows[d][m] <= f.limit {
+		f.docs = append(f.docs, n.docs...)
+	}
+	return true
+}
+
+// nextRow считает строку расстояний Дамерау — Левенштейна (как
+// в wordDistance) для path по строкам предыдущих букв и её минимум.
+// Считается только полоса |d-j| <= limit, вне неё хранится limit+1.
+func (f *fuzzyWalk) nextRow() ([]int, int) {
+	d, m, over := len(f.path), len(f.q), f.limit+1
+	if d == len(f.rows) {
+		f.rows = append(f.rows, make([]int, m+1))
+	}
+	up, row := f.rows[d-1], f.rows[d]
+	w, q := f.path, f.q
+	row[0] = min(d, over)
+	lo, hi := max(1, d-f.limit), min(m, d+f.limit)
+	if lo > m {
+		return row, over
+	}
+	if lo > 1 {
+		row[lo-1] = over
+	}
+	best := row[0]
+	for j := lo; j <= hi; j++ {
+		cost := 1
+		if w[d-1] == q[j-1] {
+			cost = 0
+		}
+		v := min(up[j]+1, row[j-1]+1, up[j-1]+cost)
+		if d > 1 && j > 1 && w[d-1] == q[j-2] && w[d-2] == q[j-1] {
+			v = min(v, f.rows[d-2][j-2]+1)
+		}
+		row[j] = min(v, over)
+		best = min(best, row[j])
+	}
+	if hi < m {
+		row[hi+1] = over
+	}
+	return row, best
+}
+
+func commonPrefix(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// candidate — живой документ и место его записи в Vault.Accounts
+type candidate struct {
+	pos int
+	doc uint32
+}
+
+// candidates возвращает записи документов в порядке Vault.Accounts;
+// n — число записей. Пустой список — не nil.
+func (x *searchIndex) candidates(docs []uint32, n int) []candidate {
+	cands := make([]candidate, 0, len(docs))
+	for _, doc := range docs {
+		if i, ok := x.pos[x.ids[doc]]; ok && i < n {
+			cands = append(cands, candidate{i, doc})
+		}
+	}
+	slices.SortFunc(cands, func(a, b candidate) int { return cmp.Compare(a.pos, b.pos) })
+	return cands
+}
+
+// loweredField — открытое поле записи в нижнем регистре, его вес и поле
+// запроса, к которому оно относится ("" — только поиск по всем полям)
+type loweredField struct {
+	text   string
+	weight float64
+	field  string
+}
+
+// openFields собирает непустые открытые поля записи в нижнем регистре
+// по убыванию веса — в этом порядке их сравнивает matchFields. Адрес
+// идёт вместе со своим хостом.
+func openFields(acc Account) []loweredField {
+	var fields []loweredField
+	add := func(text string, weight float64, field string) {
+		if text != "" {
+			fields = append(fields, loweredField{strings.ToLower(text), weight, field})
+		}
+	}
+	add(acc.Name, weightName, "name")
+	add(acc.Login, weightLogin, "login")
+	for _, u := range acc.URIs {
+		add(u.URL, weightURL, "url")
+		add(hostOf(u.URL), weightURL, "url")
+	}
+	for _, t := range acc.Tags {
+		add(t, weightTag, "tag")
+	}
+	add(acc.Folder, weightTag, "folder")
+	add(acc.Notes, weightOther, "")
+	// У логина searchText повторяет логин и адреса с меньшим весом
+	if acc.Kind() != TypeLogin {
+		for _, s := range acc.searchText() {
+			add(s, weightOther, "")
+		}
+	}
+	for _, f := range acc.Fields {
+		add(f.Name, weightOther, "")
+		if f.Searchable() {
+			add(f.Value, weightOther, "")
+		}
+	}
+	return fields
+}
+
+// splitWords делит текст на слова так же, как fuzzyWords
+func splitWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// trigrams возвращает различные триграммы строки, упакованные в числа
+func trigrams(s string) []uint64 {
+	if utf8.RuneCountInString(s) < 3 {
+		return nil
+	}
+	var grams []uint64
+	var a, b rune
+	for j, c := range []rune(s) {
+		if j >= 2 {
+			grams = append(grams, uint64(a)<<42|uint64(b)<<21|uint64(c))
+		}
+		a, b = b, c
+	}
+	slices.Sort(grams)
+	return slices.Compact(grams)
+}
+
+// intersectDocs пересекает упорядоченные списки; a должен быть короче
+func intersectDocs(a, b []uint32) []uint32 {
+	var out []uint32
+	for _, doc := range a {
+		i, ok := slices.BinarySearch(b, doc)
+		if ok {
+			out = append(out, doc)
+		}
+		if b = b[i:]; len(b) == 0 {
+			break
+		}
+	}
+	return out
+}
+
+// unionDocs объединяет упорядоченные списки
+func unionDocs(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, max(len(a), len(b)))
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			out, a = append(out, a[0]), a[1:]
+		case a[0] > b[0]:
+			out, b = append(out, b[0]), b[1:]
+		default:
+			out, a, b = append(out, a[0]), a[1:], b[1:]
+		}
+	}
+	out = append(out, a...)
+	return append(out, b...)
+}
+
+// estimate — верхняя оценка числа точных совпадений подстроки q:
+// длина самого короткого списка её триграмм
+func (x *searchIndex) estimate(q string) int {
+	grams := trigrams(q)
+	if len(grams) == 0 {
+		return len(x.docOf)
+	}
+	n := len(x.ids)
+	for _, g := range grams {
+		n = min(n, len(x.grams[g]))
+	}
+	return n
+}
+
+// Поддержка индекса сейфом. Методы *Locked вызываются под v.Lock;
+// пока индекс не построен, они ничего не делают.
+
+// ensureIndex строит индекс при первом поиске или после сброса
+func (v *VaultWithDb) ensureIndex() {
+	v.RLock()
+	ready := v.search != nil
+	v.RUnlock()
+	if ready {
+		return
+	}
+	v.Lock()
+	if v.search == nil {
+		v.search = newSearchIndex(v.Data.Accounts)
+	}
+	v.Unlock()
+}
+
+// reindexLocked обновляет документ записи на месте i
+func (v *VaultWithDb) reindexLocked(i int) {
+	if v.search == nil {
+		return
+	}
+	acc := v.Data.Accounts[i]
+	v.search.remove(acc.ID)
+	v.search.add(acc, i)
+	if v.search.stale() {
+		v.search = nil
+	}
+}
+
+// unindexLocked убирает из индекса запись id, стоявшую на месте i;
+// записи после неё уже сдвинуты на одно место назад
+func (v *VaultWithDb) unindexLocked(id string, i int) {
+	if v.search == nil {
+		return
+	}
+	v.search.remove(id)
+	for j := i; j < len(v.Data.Accounts); j++ {
+		v.search.pos[v.Data.Accounts[j].ID] = j
+	}
+	if v.search.stale() {
+		v.search = nil
+	}
+}
@@ -0,0 +1,236 @@
+package account
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testServices = []string{
+		"GitHub", "GitLab", "Google", "Gmail", "Yandex", "Сбербанк", "Тинькофф", "Альфа-Банк",
+		"Amazon", "Steam", "Discord", "Slack", "Telegram", "ВКонтакте", "Госуслуги", "Ozon",
+		"Wildberries", "Netflix", "Spotify", "Dropbox", "Nextcloud", "Jira", "Confluence", "AWS",
+		"Azure", "DigitalOcean", "Hetzner", "Cloudflare", "PayPal", "Revolut", "Binance", "Bank of Omega",
+	}
+	testTags    = []string{"work", "home", "bank", "dev", "games", "shop", "финансы", "почта"}
+	testFolders = []string{"", "Работа", "Работа/Серверы", "Личное", "Финансы", "Игры"}
+	testWords   = testVocabulary(3000)
+)
+
+// testVocabulary собирает псевдослова из слогов, чтобы заметки были похожи
+// на текст, а не на случайные буквы
+func testVocabulary(n int) []string {
+	syllables := []string{"ka", "lo", "mi", "ner", "sta", "vo", "tri", "gen", "pa", "ru", "de", "xo",
+		"ба", "ро", "ми", "ста", "ве", "ло", "ну", "кра"}
+	rnd := rand.New(rand.NewPCG(7, 7))
+	words := make([]string, n)
+	for i := range words {
+		var b strings.Builder
+		for range 2 + rnd.IntN(3) {
+			b.WriteString(syllables[rnd.IntN(len(syllables))])
+		}
+		words[i] = b.String()
+	}
+	return words
+}
+
+// testAccount создаёт правдоподобный логин: частые слова встречаются
+// во многих записях, редкие — в единицах
+func testAccount(rnd *rand.Rand, n int) Account {
+	service := testServices[rnd.IntN(len(testServices))]
+	host := strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(service))
+	acc := Account{
+		ID:     NewID(),
+		Type:   TypeLogin,
+		Name:   fmt.Sprintf("%s %d", service, n),
+		Login:  fmt.Sprintf("user%d@%s.example", rnd.IntN(5000), host),
+		URIs:   []URI{{URL: fmt.Sprintf("https://%s%d.example.com/login", host, rnd.IntN(100))}},
+		Folder: testFolders[rnd.IntN(len(testFolders))],
+		Tags:   NormalizeTags([]string{testTags[rnd.IntN(len(testTags))]}),
+	}
+	var notes []string
+	for range rnd.IntN(12) {
+		// Квадрат случайного числа — частые слова в начале словаря
+		r := rnd.Float64()
+		notes = append(notes, testWords[int(r*r*float64(len(testWords)))])
+	}
+	acc.Notes = strings.Join(notes, " ")
+	if rnd.IntN(10) == 0 {
+		acc.Fields = []CustomField{{Name: "Контрольный вопрос", Type: FieldText, Value: testWords[rnd.IntN(len(testWords))]}}
+	}
+	acc.Favorite = rnd.IntN(50) == 0
+	return acc
+}
+
+func testVault(n int, seed uint64) (*VaultWithDb, *rand.Rand) {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+	v := &VaultWithDb{}
+	for i := range n {
+		v.Data.Accounts = append(v.Data.Accounts, testAccount(rnd, i))
+	}
+	return v, rnd
+}
+
+var testQueries = []string{
+	"github", "githb", "gogle", "сбербнк", "omgea", "bank", "банк",
+	"name:steam", "login:user12", "url:amazon", "tag:финансы", "folder:Работа",
+	`"bank of omega"`, "github OR gitlab", "-tag:work steam", "(tag:dev OR tag:home) login:user1",
+	"ka", "kalo", "стаба", "контрольный", "личное", "серверы", "type:login has:fields",
+	"nonexistentword", "a",
+}
+
+// checkIndex сравнивает результаты Query с полным перебором без индекса
+func checkIndex(t *testing.T, v *VaultWithDb) {
+	t.Helper()
+	for _, q := range testQueries {
+		got, err := v.Query(q)
+		if err != nil {
+			t.Fatalf("Query(%q): %v", q, err)
+		}
+		node, _ := parseQuery(q)
+		// У записей теста нет истории использования, поэтому оценки
+		// не зависят от времени и совпадают точно
+		v.RLock()
+		want, _ := rankResults(node, v.Data.Accounts, nil, nil, 0, time.Now())
+		v.RUnlock()
+		if len(got) != len(want) {
+			t.Fatalf("Query(%q): %d результатов, перебор нашёл %d", q, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Account.ID != want[i].Account.ID || got[i].Score != want[i].Score {
+				t.Fatalf("Query(%q)[%d]: %s (%g), перебор — %s (%g)", q, i,
+					got[i].Account.Name, got[i].Score, want[i].Account.Name, want[i].Score)
+			}
+		}
+		// Лучшие limit результатов — начало полного списка
+		top, total, _ := v.QueryTop(q, 7)
+		if total != len(want) || len(top) != min(7, len(want)) {
+			t.Fatalf("QueryTop(%q, 7): %d из %d, ожидалось %d из %d", q, len(top), total, min(7, len(want)), len(want))
+		}
+		for i := range top {
+			if top[i].Account.ID != want[i].Account.ID {
+				t.Fatalf("QueryTop(%q, 7)[%d]: %s, ожидалась %s", q, i, top[i].Account.Name, want[i].Account.Name)
+			}
+		}
+	}
+	for _, acc := range v.Data.Accounts {
+		if got, ok := v.Get(acc.ID); !ok || got.Name != acc.Name {
+			t.Fatalf("Get(%s) не нашёл запись %q", acc.ID, acc.Name)
+		}
+	}
+}
+
+func TestIndexMatchesFullScan(t *testing.T) {
+	v, rnd := testVault(800, 1)
+	checkIndex(t, v)
+
+	next := len(v.Data.Accounts)
+	for step := range 2000 {
+		v.RLock()
+		id := v.Data.Accounts[rnd.IntN(len(v.Data.Accounts))].ID
+		v.RUnlock()
+		switch rnd.IntN(6) {
+		case 0:
+			v.AddAccount(testAccount(rnd, next))
+			next++
+		case 1:
+			v.Delete(id)
+		case 2:
+			name := fmt.Sprintf("%s %d", testServices[rnd.IntN(len(testServices))], next)
+			notes := testWords[rnd.IntN(len(testWords))]
+			if _, err := v.Update(id, AccountUpdate{Name: &name, Notes: &notes}); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+		case 3:
+			if len(v.Data.Trash) > 0 {
+				if err := v.Restore(v.Data.Trash[rnd.IntN(len(v.Data.Trash))].ID); err != nil {
+					t.Fatalf("Restore: %v", err)
+				}
+			}
+		case 4:
+			v.Retag([]string{id}, []string{testTags[rnd.IntN(len(testTags))]}, []string{testTags[rnd.IntN(len(testTags))]})
+		case 5:
+			v.Move([]string{id}, testFolders[rnd.IntN(len(testFolders))])
+		}
+		if step%400 == 399 {
+			checkIndex(t, v)
+		}
+	}
+	checkIndex(t, v)
+}
+
+func TestIndexReplaceAccounts(t *testing.T) {
+	v, rnd := testVault(300, 2)
+	checkIndex(t, v)
+	var fresh []Account
+	for i := range 200 {
+		fresh = append(fresh, testAccount(rnd, 1000+i))
+	}
+	if err := v.ReplaceAccounts(fresh); err != nil {
+		t.Fatal(err)
+	}
+	checkIndex(t, v)
+}
+
+// BenchmarkQuery50k измеряет поиск в сейфе на 50 000 записей. Индекс
+// строится до замера. Время растёт с числом найденных записей: их нужно
+// оценить, поэтому частые слова ищутся дольше редких. top50 — поиск
+// для экрана: сортируются и копируются только 50 лучших.
+func BenchmarkQuery50k(b *testing.B) {
+	v, _ := testVault(50000, 3)
+	v.ensureIndex()
+	sample := v.Data.Accounts[12345]
+	rare := testWords[len(testWords)-1]
+	cases := []struct{ kind, q string }{
+		{"selective", fmt.Sprintf("name:%q", sample.Name)},
+		{"selective", fmt.Sprintf("%q", sample.Login)},
+		{"selective", rare},
+		{"selective", "nonexistentword"},
+		{"fuzzy", swapRunes(rare)},
+		{"fuzzy", "omgea"},
+		{"fuzzy", "githb"},
+		{"fuzzy", "сбербнк"},
+		{"common", "bank"},
+		{"common", "tag:work"},
+		{"common", "login:user4242"},
+		{"common", testWords[0]},
+	}
+	for _, c := range cases {
+		for _, limit := range []int{0, 50} {
+			name := c.kind + "/" + c.q
+			if limit > 0 {
+				name = fmt.Sprintf("top%d/%s", limit, name)
+			}
+			b.Run(name, func(b *testing.B) {
+				var n int
+				for b.Loop() {
+					_, total, err := v.QueryTop(c.q, limit)
+					if err != nil {
+						b.Fatal(err)
+					}
+					n = total
+				}
+				b.ReportMetric(float64(n), "results")
+			})
+		}
+	}
+}
+
+// swapRunes переставляет две соседние буквы в середине слова — типичная опечатка
+func swapRunes(s string) string {
+	rs := []rune(s)
+	i := len(rs) / 2
+	rs[i-1], rs[i] = rs[i], rs[i-1]
+	return string(rs)
+}
+
+// BenchmarkIndexBuild50k — построение индекса при первом поиске
+func BenchmarkIndexBuild50k(b *testing.B) {
+	v, _ := testVault(50000, 3)
+	for b.Loop() {
+		v.search = newSearchIndex(v.Data.Accounts)
+	}
+}
@@ -0,0 +1,427 @@
+package account
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ItemType — вид записи в сейфе
+type ItemType string
+
+const (
+	TypeLogin    ItemType = "login"    // логин, пароль и URL сайта
+	TypeNote     ItemType = "note"     // защищённая заметка: текст хранится в Notes
+	TypeCard     ItemType = "card"     // банковская карта
+	TypeIdentity ItemType = "identity" // личные данные и документ
+	TypeSSHKey   ItemType = "ssh"      // SSH-ключ; пароль ключа хранится в Password
+	TypeAPI      ItemType = "api"      // доступ к API; секрет хранится в Password
+)
+
+// ItemTypes — все виды записей в порядке показа в меню
+var ItemTypes = []ItemType{TypeLogin, TypeNote, TypeCard, TypeIdentity, TypeSSHKey, TypeAPI}
+
+func (t ItemType) String() string {
+	switch t {
+	case TypeLogin:
+		return "логин"
+	case TypeNote:
+		return "заметка"
+	case TypeCard:
+		return "карта"
+	case TypeIdentity:
+		return "личность"
+	case TypeSSHKey:
+		return "SSH-ключ"
+	case TypeAPI:
+		return "API"
+	default:
+		return string(t)
+	}
+}
+
+// ParseItemType принимает идентификатор вида (card) или его название (карта)
+func ParseItemType(s string) (ItemType, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, t := range ItemTypes {
+		if s == string(t) || s == strings.ToLower(t.String()) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// Card — данные банковской карты
+type Card struct {
+	Holder string `json:"holder,omitempty"`
+	Number string `json:"number"`           // только цифры
+	Expiry string `json:"expiry,omitempty"` // ММ/ГГ
+	CVV    string `json:"cvv,omitempty"`
+	PIN    string `json:"pin,omitempty"`
+	Brand  string `json:"brand,omitempty"`
+}
+
+// Identity — личные данные и документ, удостоверяющий личность
+type Identity struct {
+	FullName       string `json:"fullName,omitempty"`
+	BirthDate      string `json:"birthDate,omitempty"` // ДД.ММ.ГГГГ
+	Document       string `json:"document,omitempty"`  // паспорт, права и т. п.
+	DocumentNumber string `json:"documentNumber,omitempty"`
+	Email          string `json:"email,omitempty"`
+	Phone          string `json:"phone,omitempty"`
+	Address        string `json:"address,omitempty"`
+}
+
+// SSHKey — пара SSH-ключей в текстовом виде
+type SSHKey struct {
+	PrivateKey string `json:"privateKey"`          // PEM
+	PublicKey  string `json:"publicKey,omitempty"` // строка authorized_keys
+}
+
+// APICredential — идентификатор ключа API и адрес сервиса
+type APICredential struct {
+	Key      string `json:"key,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+const (
+	cardExpiryLayout = "01/06"
+	birthDateLayout  = "02.01.2006"
+)
+
+// newItem заполняет общие поля записи заданного вида
+func newItem(t ItemType, name string) *Account {
+	now := time.Now()
+	return &Account{
+		ID:        NewID(),
+		Type:      t,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// NewSecureNote создаёт защищённую заметку
+func NewSecureNote(name, text string) (*Account, error) {
+	acc := newItem(TypeNote, name)
+	acc.Notes = text
+	return acc, acc.validate()
+}
+
+// NewCard создаёт запись банковской карты
+func NewCard(name string, card Card) (*Account, error) {
+	acc := newItem(TypeCard, name)
+	card.Number = stripSeparators(card.Number)
+	acc.Card = &card
+	return acc, acc.validate()
+}
+
+// NewIdentity создаёт запись с личными данными
+func NewIdentity(name string, id Identity) (*Account, error) {
+	acc := newItem(TypeIdentity, name)
+	acc.Identity = &id
+	return acc, acc.validate()
+}
+
+// NewSSHKey создаёт запись SSH-ключа; passphrase — пароль закрытого ключа
+func NewSSHKey(name string, key SSHKey, passphrase string) (*Account, error) {
+	acc := newItem(TypeSSHKey, name)
+	acc.SSHKey = &key
+	acc.Password = passphrase
+	return acc, acc.validate()
+}
+
+// NewAPICredential создаёт запись доступа к API; secret — секретная часть ключа
+func NewAPICredential(name string, cred APICredential, secret string) (*Account, error) {
+	acc := newItem(TypeAPI, name)
+	acc.API = &cred
+	acc.Password = secret
+	return acc, acc.validate()
+}
+
+// Kind возвращает вид записи; записи старых версий без вида считаются логинами
+func (acc Account) Kind() ItemType {
+	if acc.Type == "" {
+		return TypeLogin
+	}
+	return acc.Type
+}
+
+// validateData проверяет данные, зависящие от вида записи
+func (acc *Account) validateData() error {
+	switch acc.Kind() {
+	case TypeLogin:
+		if acc.Login == "" {
+			return errors.New("некорректный логин")
+		}
+		if len(acc.URIs) == 0 {
+			return errors.New("укажите хотя бы один URL")
+		}
+	case TypeNote:
+		if acc.Name == "" {
+			return errors.New("у заметки должно быть имя")
+		}
+	case TypeCard:
+		if acc.Card == nil {
+			return errors.New("нет данных карты")
+		}
+		return acc.Card.validate()
+	case TypeIdentity:
+		if acc.Identity == nil {
+			return errors.New("нет личных данных")
+		}
+		return acc.Identity.validate()
+	case TypeSSHKey:
+		if acc.SSHKey == nil {
+			return errors.New("нет SSH-ключа")
+		}
+		return acc.SSHKey.validate()
+	case TypeAPI:
+		if acc.API == nil {
+			return errors.New("нет данных API")
+		}
+		if acc.API.Key == "" && acc.Password == "" {
+			return errors.New("укажите ключ или секрет API")
+		}
+		if acc.API.Endpoint != "" {
+			if _, err := url.ParseRequestURI(acc.API.Endpoint); err != nil {
+				return errors.New("некорректный адрес API")
+			}
+		}
+	default:
+		return fmt.Errorf("неизвестный вид записи %q", acc.Type)
+	}
+	return nil
+}
+
+func (c *Card) validate() error {
+	c.Number = stripSeparators(c.Number)
+	if len(c.Number) < 12 || len(c.Number) > 19 || !luhn(c.Number) {
+		return errors.New("некорректный номер карты")
+	}
+	if c.Expiry != "" {
+		if _, err := time.Parse(cardExpiryLayout, c.Expiry); err != nil {
+			return errors.New("срок действия карты — в формате ММ/ГГ")
+		}
+	}
+	if c.CVV != "" && (!isDigits(c.CVV) || len(c.CVV) < 3 || len(c.CVV) > 4) {
+		return errors.New("CVV — 3 или 4 цифры")
+	}
+	if c.PIN != "" && (!isDigits(c.PIN) || len(c.PIN) < 4 || len(c.PIN) > 12) {
+		return errors.New("PIN — от 4 до 12 цифр")
+	}
+	return nil
+}
+
+// Masked возвращает номер карты, в котором видны только последние 4 цифры
+func (c Card) Masked() string {
+	if len(c.Number) <= 4 {
+		return "****"
+	}
+	return "**** " + c.Number[len(c.Number)-4:]
+}
+
+func (id Identity) validate() error {
+	if id.FullName == "" && id.DocumentNumber == "" {
+		return errors.New("укажите имя или номер документа")
+	}
+	if id.BirthDate != "" {
+		if _, err := time.Parse(birthDateLayout, id.BirthDate); err != nil {
+			return errors.New("дата рождения — в формате ДД.ММ.ГГГГ")
+		}
+	}
+	if id.Email != "" {
+		if _, err := mail.ParseAddress(id.Email); err != nil {
+			return errors.New("некорректный email")
+		}
+	}
+	return nil
+}
+
+func (k SSHKey) validate() error {
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return errors.New("закрытый ключ должен быть в формате PEM")
+	}
+	if k.PublicKey != "" {
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey)); err != nil {
+			return errors.New("некорректный открытый ключ")
+		}
+	}
+	return nil
+}
+
+// Summary — короткое описание записи для списков: логин, номер карты и т. п.
+func (acc Account) Summary() string {
+	switch acc.Kind() {
+	case TypeLogin:
+		return acc.Login
+	case TypeCard:
+		if acc.Card != nil {
+			return "карта " + acc.Card.Masked()
+		}
+	case TypeIdentity:
+		if acc.Identity != nil && acc.Identity.FullName != "" {
+			return acc.Identity.FullName
+		}
+	case TypeAPI:
+		if acc.API != nil && acc.API.Endpoint != "" {
+			return acc.API.Endpoint
+		}
+	}
+	return acc.Kind().String()
+}
+
+// Secret возвращает главный секрет записи, который копируется в буфер обмена
+func (acc Account) Secret() (label, value string) {
+	switch acc.Kind() {
+	case TypeNote:
+		return "Заметка", acc.Notes
+	case TypeCard:
+		if acc.Card != nil {
+			return "Номер карты", acc.Card.Number
+		}
+	case TypeIdentity:
+		if acc.Identity != nil {
+			return "Номер документа", acc.Identity.DocumentNumber
+		}
+	case TypeSSHKey:
+		if acc.SSHKey != nil {
+			return "Закрытый ключ", acc.SSHKey.PrivateKey
+		}
+	case TypeAPI:
+		if acc.Password == "" && acc.API != nil {
+			return "Ключ API", acc.API.Key
+		}
+		return "Секрет API", acc.Password
+	}
+	return "Пароль", acc.Password
+}
+
+// outputData печатает поля, зависящие от вида записи; секреты маскируются
+func (acc Account) outputData() {
+	switch acc.Kind() {
+	case TypeLogin:
+		fmt.Printf("Логин: %s\n", acc.Login)
+		fmt.Printf("Пароль: %s\n", MaskPassword(acc.Password))
+		for _, u := range acc.URIs {
+			fmt.Printf("URL: %s (%s)\n", u.URL, u.Match)
+		}
+	case TypeCard:
+		if c := acc.Card; c != nil {
+			printIf("Держатель", c.Holder)
+			fmt.Printf("Номер: %s\n", c.Masked())
+			printIf("Срок действия", c.Expiry)
+			printIf("Платёжная система", c.Brand)
+			if c.CVV != "" {
+				fmt.Println("CVV: ***")
+			}
+			if c.PIN != "" {
+				fmt.Println("PIN: ****")
+			}
+		}
+	case TypeIdentity:
+		if id := acc.Identity; id != nil {
+			printIf("ФИО", id.FullName)
+			printIf("Дата рождения", id.BirthDate)
+			printIf("Документ", id.Document)
+			if id.DocumentNumber != "" {
+				fmt.Printf("Номер документа: %s\n", MaskPassword(id.DocumentNumber))
+			}
+			printIf("Email", id.Email)
+			printIf("Телефон", id.Phone)
+			printIf("Адрес", id.Address)
+		}
+	case TypeSSHKey:
+		if k := acc.SSHKey; k != nil {
+			fmt.Println("Закрытый ключ: (скрыт)")
+			printIf("Открытый ключ", k.PublicKey)
+			if acc.Password != "" {
+				fmt.Printf("Пароль ключа: %s\n", MaskPassword(acc.Password))
+			}
+		}
+	case TypeAPI:
+		if a := acc.API; a != nil {
+			printIf("Адрес", a.Endpoint)
+			printIf("Ключ", a.Key)
+			if acc.Password != "" {
+				fmt.Printf("Секрет: %s\n", MaskPassword(acc.Password))
+			}
+		}
+	}
+}
+
+// searchText — открытые поля вида записи, по которым работает поиск
+func (acc Account) searchText() []string {
+	switch acc.Kind() {
+	case TypeLogin:
+		text := []string{acc.Login}
+		for _, u := range acc.URIs {
+			text = append(text, u.URL)
+		}
+		return text
+	case TypeCard:
+		if c := acc.Card; c != nil {
+			return []string{c.Holder, c.Brand}
+		}
+	case TypeIdentity:
+		if id := acc.Identity; id != nil {
+			return []string{id.FullName, id.Document, id.Email, id.Phone}
+		}
+	case TypeAPI:
+		if a := acc.API; a != nil {
+			return []string{a.Endpoint}
+		}
+	}
+	return nil
+}
+
+func printIf(label, value string) {
+	if value != "" {
+		fmt.Printf("%s: %s\n", label, value)
+	}
+}
+
+// stripSeparators убирает пробелы и дефисы, которыми разделяют группы цифр
+func stripSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// luhn проверяет контрольную цифру номера карты
+func luhn(number string) bool {
+	if !isDigits(number) {
+		return false
+	}
+	sum := 0
+	for i := range len(number) {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,81 @@
+package account
+
+import (
+	"net/url"
+	"strings"
+)
+
+// MatchMode — способ сравнения адресов записи с запросом пользователя,
+// например при удалении по URL. Подбор записей для страницы идёт по
+// собственным правилам адресов — см. FindByURL.
+type MatchMode int
+
+const (
+	MatchExact     MatchMode = iota // URL совпадает целиком
+	MatchHost                       // совпадает имя хоста
+	MatchSubstring                  // запрос входит в URL
+)
+
+func (m MatchMode) String() string {
+	switch m {
+	case MatchExact:
+		return "точный URL"
+	case MatchHost:
+		return "хост"
+	case MatchSubstring:
+		return "часть URL"
+	default:
+		return "неизвестный режим"
+	}
+}
+
+// MatchURL сравнивает URL аккаунта с запросом без учёта регистра
+func MatchURL(accountURL, query string, mode MatchMode) bool {
+	if query == "" {
+		return false
+	}
+	switch mode {
+	case MatchExact:
+		return normalizeURL(accountURL) == normalizeURL(query)
+	case MatchHost:
+		host := hostOf(query)
+		return host != "" && hostOf(accountURL) == host
+	case MatchSubstring:
+		return strings.Contains(strings.ToLower(accountURL), strings.ToLower(query))
+	default:
+		return false
+	}
+}
+
+// SearchByURL возвращает аккаунты, хотя бы один адрес которых подходит под запрос
+func (v *VaultWithDb) SearchByURL(query string, mode MatchMode) []Account {
+	v.RLock()
+	defer v.RUnlock()
+	var accounts []Account
+	for _, acc := range v.Data.Accounts {
+		for _, u := range acc.URIs {
+			if MatchURL(u.URL, query, mode) {
+				accounts = append(accounts, acc)
+				break
+			}
+		}
+	}
+	return accounts
+}
+
+// hostOf возвращает хост в нижнем регистре; запрос без схемы
+// ("example.com/login") тоже разбирается
+func hostOf(raw string) string {
+	return hostPort(raw).host
+}
+
+// normalizeURL приводит схему и хост к нижнему регистру и убирает завершающий "/"
+func normalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return strings.TrimSpace(raw)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return strings.TrimSuffix(u.String(), "/")
+}
@@ -0,0 +1,157 @@
+package account
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Filter — условия поиска: подстрока в открытых полях, тег и папка.
+// Пустое условие не ограничивает выборку; папка включает вложенные.
+type Filter struct {
+	Query  string
+	Tag    string
+	Folder string
+}
+
+// Search возвращает аккаунты, подходящие под все условия фильтра,
+// в порядке RankAccounts
+func (v *VaultWithDb) Search(f Filter) []Account {
+	v.RLock()
+	defer v.RUnlock()
+	q := strings.ToLower(f.Query)
+	tag := NormalizeTag(f.Tag)
+	folder := NormalizeFolder(f.Folder)
+
+	var accounts []Account
+	for _, acc := range v.Data.Accounts {
+		if q != "" && !acc.matches(q) {
+			continue
+		}
+		if tag != "" && !slices.Contains(acc.Tags, tag) {
+			continue
+		}
+		if folder != "" && !InFolder(acc.Folder, folder) {
+			continue
+		}
+		accounts = append(accounts, acc)
+	}
+	RankAccounts(accounts, time.Now())
+	return accounts
+}
+
+// Folders возвращает все папки сейфа, включая промежуточные, по алфавиту
+func (v *VaultWithDb) Folders() []string {
+	v.RLock()
+	defer v.RUnlock()
+	seen := make(map[string]bool)
+	for _, acc := range v.Data.Accounts {
+		if acc.Folder == "" {
+			continue
+		}
+		parts := strings.Split(acc.Folder, "/")
+		for i := range parts {
+			seen[strings.Join(parts[:i+1], "/")] = true
+		}
+	}
+	folders := make([]string, 0, len(seen))
+	for f := range seen {
+		folders = append(folders, f)
+	}
+	slices.Sort(folders)
+	return folders
+}
+
+// Retag добавляет и удаляет теги у аккаунтов с данными ID и возвращает
+// число изменённых записей
+func (v *VaultWithDb) Retag(ids []string, add, remove []string) int {
+	v.Lock()
+	defer v.Unlock()
+	changed := 0
+	now := time.Now()
+	for _, id := range ids {
+		i := v.indexOf(id)
+		if i < 0 {
+			continue
+		}
+		acc := &v.Data.Accounts[i]
+		tags := slices.DeleteFunc(slices.Clone(acc.Tags), func(t string) bool {
+			return slices.Contains(NormalizeTags(remove), t)
+		})
+		tags = NormalizeTags(append(tags, add...))
+		if slices.Equal(tags, acc.Tags) {
+			continue
+		}
+		acc.Tags = tags
+		acc.UpdatedAt = now
+		v.reindexLocked(i)
+		changed++
+	}
+	if changed > 0 {
+		v.Data.UpdatedAt = now
+	}
+	return changed
+}
+
+// Move переносит аккаунты с данными ID в папку ("" — в корень)
+// и возвращает число перенесённых записей
+func (v *VaultWithDb) Move(ids []string, folder string) int {
+	v.Lock()
+	defer v.Unlock()
+	folder = NormalizeFolder(folder)
+	moved := 0
+	now := time.Now()
+	for _, id := range ids {
+		i := v.indexOf(id)
+		if i < 0 || v.Data.Accounts[i].Folder == folder {
+			continue
+		}
+		v.Data.Accounts[i].Folder = folder
+		v.Data.Accounts[i].UpdatedAt = now
+		v.reindexLocked(i)
+		moved++
+	}
+	if moved > 0 {
+		v.Data.UpdatedAt = now
+	}
+	return moved
+}
+
+// NormalizeTag приводит тег к нижнему регистру без пробелов по краям
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags нормализует теги, убирает пустые и повторы и сортирует их
+func NormalizeTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		if t = NormalizeTag(t); t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// ParseTags разбирает теги, перечисленные через запятую
+func ParseTags(s string) []string {
+	return NormalizeTags(strings.Split(s, ","))
+}
+
+// NormalizeFolder убирает лишние "/" и пробелы: " Работа//Серверы/ " → "Работа/Серверы"
+func NormalizeFolder(folder string) string {
+	var parts []string
+	for _, p := range strings.Split(folder, "/") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// InFolder сообщает, лежит ли папка folder внутри parent или совпадает с ней
+func InFolder(folder, parent string) bool {
+	folder, parent = strings.ToLower(folder), strings.ToLower(parent)
+	return folder == parent || strings.HasPrefix(folder, parent+"/")
+}
@@ -0,0 +1,51 @@
+package account
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNoOTP = errors.New("у аккаунта не настроена 2FA")
+
+// NextOTPCode выдаёт следующий код HOTP аккаунта и сразу сохраняет сейф
+// с увеличенным счётчиком. Если сохранить не удалось, счётчик
+// возвращается к прежнему значению, а код не выдаётся — иначе сервер
+// и сейф разойдутся в счётчиках.
+func (v *VaultWithDb) NextOTPCode(id string) (string, error) {
+	v.otpMu.Lock()
+	defer v.otpMu.Unlock()
+
+	v.Lock()
+	i := v.indexOf(id)
+	if i < 0 {
+		v.Unlock()
+		return "", ErrNotFound
+	}
+	old := v.Data.Accounts[i].OTP
+	if old == nil {
+		v.Unlock()
+		return "", ErrNoOTP
+	}
+	// Меняем копию ключа: старый указатель мог уйти наружу через Get
+	key := *old
+	code, err := key.Next()
+	if err != nil {
+		v.Unlock()
+		return "", err
+	}
+	v.Data.Accounts[i].OTP = &key
+	prevUpdated := v.Data.UpdatedAt
+	v.Data.UpdatedAt = time.Now()
+	v.Unlock()
+
+	if err := v.Save(); err != nil {
+		v.Lock()
+		if i := v.indexOf(id); i >= 0 {
+			v.Data.Accounts[i].OTP = old
+		}
+		v.Data.UpdatedAt = prevUpdated
+		v.Unlock()
+		return "", err
+	}
+	return code, nil
+}
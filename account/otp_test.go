@@ -0,0 +1,77 @@
+package account
+
+import (
+	"errors"
+	"menedger_paroley/crypto"
+	"menedger_paroley/otp"
+	"testing"
+)
+
+// flakyDb — хранилище в памяти, запись в которое можно сломать
+type flakyDb struct {
+	data []byte
+	err  error
+}
+
+func (d *flakyDb) Read() ([]byte, error) {
+	return d.data, nil
+}
+
+func (d *flakyDb) Write(data []byte) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.data = data
+	return nil
+}
+
+const testMasterPassword = "мастер-пароль"
+
+// testVaultWithKey создаёт сейф с быстрым ключом PBKDF2, чтобы тесты
+// не ждали Argon2id
+func testVaultWithKey(t *testing.T, db Db) *VaultWithDb {
+	t.Helper()
+	key, err := crypto.DeriveKey([]byte(testMasterPassword), crypto.Params{
+		KDF: crypto.KDFPBKDF2, Iterations: 1000, SaltLen: 16, Cipher: crypto.CipherAES256GCM,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &VaultWithDb{Db: db, key: key, verifier: key.Verifier()}
+}
+
+func TestNextOTPCodeRollsBackOnWriteError(t *testing.T) {
+	db := &flakyDb{err: errors.New("диск переполнен")}
+	v := testVaultWithKey(t, db)
+	// Секрет из RFC 4226: счётчик 0 даёт 755224, счётчик 1 — 287082
+	acc := Account{ID: NewID(), Type: TypeLogin, Name: "VPN",
+		OTP: &otp.Key{Type: otp.HOTP, Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}}
+	v.AddAccount(acc)
+	updated := v.Data.UpdatedAt
+
+	code, err := v.NextOTPCode(acc.ID)
+	if !errors.Is(err, db.err) {
+		t.Fatalf("NextOTPCode: %v, ожидалась ошибка записи", err)
+	}
+	if code != "" {
+		t.Fatalf("при ошибке записи выдан код %q", code)
+	}
+	if got, _ := v.Get(acc.ID); got.OTP.Counter != 0 {
+		t.Fatalf("после ошибки счётчик %d, ожидался 0", got.OTP.Counter)
+	}
+	if !v.Data.UpdatedAt.Equal(updated) {
+		t.Errorf("после ошибки UpdatedAt изменился")
+	}
+
+	db.err = nil
+	if code, err = v.NextOTPCode(acc.ID); err != nil || code != "755224" {
+		t.Fatalf("NextOTPCode = %q, %v; ожидался 755224", code, err)
+	}
+	reopened, err := OpenVault(db, db.data, testMasterPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reopened.Get(acc.ID); got.OTP.Counter != 1 {
+		t.Fatalf("в сохранённом сейфе счётчик %d, ожидался 1", got.OTP.Counter)
+	}
+}
@@ -0,0 +1,858 @@
+package account
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Язык запросов поиска:
+//
+//	банк                     слово в любом открытом поле, с опечатками
+//	"мой банк"               фраза целиком
+//	name: login: url:        поиск в одном поле
+//	tag: folder: type:       тег, папка (с вложенными), вид записи
+//	has:otp                  есть 2FA; также has:attachment, has:notes,
+//	                         has:fields, has:favorite
+//	a b, a AND b             оба условия
+//	a OR b, a | b            хотя бы одно
+//	NOT a, -a                условие не выполняется
+//	( … )                    группировка
+//
+// Вместо AND, OR, NOT можно писать И, ИЛИ, НЕ.
+
+var ErrQuerySyntax = errors.New("ошибка в запросе")
+
+// SearchResult — найденная запись и её релевантность
+type SearchResult struct {
+	Account Account
+	Score   float64
+}
+
+// Query ищет записи по запросу и возвращает их по убыванию релевантности.
+// Избранные и часто используемые записи получают небольшую прибавку.
+// Кандидатов подбирает индекс, поэтому поиск по словам не просматривает
+// все записи сейфа.
+func (v *VaultWithDb) Query(q string) ([]SearchResult, error) {
+	results, _, err := v.QueryTop(q, 0)
+	return results, err
+}
+
+// QueryTop — Query, который возвращает только limit лучших результатов
+// (0 — все) и общее число найденных. Оценить приходится всех кандидатов,
+// но сортируются и копируются только лучшие, поэтому для показа на экране
+// QueryTop заметно быстрее Query, когда находятся тысячи записей.
+func (v *VaultWithDb) QueryTop(q string, limit int) ([]SearchResult, int, error) {
+	node, err := parseQuery(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	v.ensureIndex()
+	v.RLock()
+	defer v.RUnlock()
+	var cands []candidate
+	if node != nil && v.search != nil {
+		if docs, ok := node.plan(v.search); ok {
+			cands = v.search.candidates(docs, len(v.Data.Accounts))
+		}
+	}
+	results, total := rankResults(node, v.Data.Accounts, cands, v.search, limit, time.Now())
+	return results, total, nil
+}
+
+// MatchesExactly сообщает, подходит ли запись под запрос без учёта
+// опечаток: если нет, она нашлась только по похожему слову, и перед
+// действием с ней стоит переспросить. Запрос с ошибкой FindAccount ищет
+// подстрокой, без опечаток, поэтому для него ответ — да.
+func (acc Account) MatchesExactly(q string) bool {
+	node, err := parseQuery(q)
+	if err != nil || node == nil {
+		return true
+	}
+	ok, _ := withoutTypos(node).eval(&acc, openFields(acc))
+	return ok
+}
+
+// withoutTypos копирует запрос, запрещая опечатки во всех словах
+func withoutTypos(node queryNode) queryNode {
+	switch n := node.(type) {
+	case andNode:
+		out := make(andNode, len(n))
+		for i, child := range n {
+			out[i] = withoutTypos(child)
+		}
+		return out
+	case orNode:
+		out := make(orNode, len(n))
+		for i, child := range n {
+			out[i] = withoutTypos(child)
+		}
+		return out
+	case notNode:
+		return notNode{withoutTypos(n.child)}
+	case termNode:
+		n.exact = true
+		return n
+	default:
+		return node
+	}
+}
+
+// rankResults оценивает кандидатов (nil — все записи), сортирует
+// подходящие и возвращает limit лучших (0 — все) и число подходящих.
+// Открытые поля в нижнем регистре берутся из индекса x, а без него
+// собираются заново. Сортируются места записей, а сами записи копируются
+// только в результат: копировать их при каждой перестановке дорого.
+// При равных оценке и использовании порядок — как в сейфе.
+func rankResults(node queryNode, accounts []Account, cands []candidate, x *searchIndex, limit int, now time.Time) ([]SearchResult, int) {
+	type scored struct {
+		pos   int
+		score float64
+	}
+	var found []scored
+	check := func(i int, fields []loweredField) {
+		acc := &accounts[i]
+		ok, score := true, 0.0
+		if node != nil {
+			ok, score = node.eval(acc, fields)
+		}
+		if ok {
+			found = append(found, scored{i, score * usageBoost(acc, now)})
+		}
+	}
+	switch {
+	case cands != nil:
+		for _, c := range cands {
+			check(c.pos, x.texts[c.doc])
+		}
+	case node == nil:
+		for i := range accounts {
+			check(i, nil)
+		}
+	default:
+		for i := range accounts {
+			check(i, x.fieldsOf(&accounts[i]))
+		}
+	}
+
+	compare := func(a, b scored) int {
+		if a.score != b.score {
+			if a.score > b.score {
+				return -1
+			}
+			return 1
+		}
+		if c := compareUsage(&accounts[a.pos], &accounts[b.pos], now); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.pos, b.pos)
+	}
+	best := found
+	if limit > 0 && len(found) > limit {
+		// Частичная сортировка: держим limit лучших по порядку и вставляем
+		// на место тех, кто лучше последнего
+		best = found[:limit:limit]
+		slices.SortFunc(best, compare)
+		for _, f := range found[limit:] {
+			if compare(f, best[limit-1]) >= 0 {
+				continue
+			}
+			i, _ := slices.BinarySearchFunc(best, f, compare)
+			copy(best[i+1:], best[i:limit-1])
+			best[i] = f
+		}
+	} else {
+		slices.SortFunc(best, compare)
+	}
+	results := make([]SearchResult, len(best))
+	for i, f := range best {
+		results[i] = SearchResult{Account: accounts[f.pos], Score: f.score}
+	}
+	return results, len(found)
+}
+
+// usageBoost поднимает избранные и часто используемые записи среди
+// одинаково подходящих, но не перебивает явно лучшее совпадение
+func usageBoost(acc *Account, now time.Time) float64 {
+	boost := 1.0
+	if acc.Favorite {
+		boost += 0.2
+	}
+	return boost + 0.1*math.Log1p(acc.usageScore(now))
+}
+
+// parseQuery разбирает запрос; пустой запрос — nil, под него подходит всё
+func parseQuery(q string) (queryNode, error) {
+	tokens, err := lexQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &queryParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: лишнее %q", ErrQuerySyntax, p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+// queryNode — узел разобранного запроса
+type queryNode interface {
+	// eval сообщает, подходит ли запись, и насколько хорошо; fields —
+	// её открытые поля из openFields
+	eval(acc *Account, fields []loweredField) (bool, float64)
+	// plan подбирает по индексу документы, среди которых есть все
+	// подходящие записи; false — сузить нельзя, смотреть нужно все
+	plan(x *searchIndex) ([]uint32, bool)
+	// cost — примерное число кандидатов, которое вернёт plan
+	cost(x *searchIndex) int
+}
+
+// planRatio — если список следующего условия AND длиннее числа
+// кандидатов больше чем во столько раз, условие дешевле проверить
+// на самих записях, чем сужать по нему
+const planRatio = 8
+
+type andNode []queryNode
+
+func (n andNode) eval(acc *Account, fields []loweredField) (bool, float64) {
+	total := 0.0
+	for _, child := range n {
+		ok, score := child.eval(acc, fields)
+		if !ok {
+			return false, 0
+		}
+		total += score
+	}
+	return true, total
+}
+
+func (n andNode) plan(x *searchIndex) ([]uint32, bool) {
+	// Сначала самые редкие условия: часто уже их хватает
+	type costed struct {
+		node queryNode
+		cost int
+	}
+	children := make([]costed, len(n))
+	for i, child := range n {
+		children[i] = costed{child, child.cost(x)}
+	}
+	slices.SortStableFunc(children, func(a, b costed) int { return cmp.Compare(a.cost, b.cost) })
+
+	var docs []uint32
+	found := false
+	for _, child := range children {
+		if found && child.cost > len(docs)*planRatio {
+			break
+		}
+		got, ok := child.node.plan(x)
+		switch {
+		case !ok:
+		case !found:
+			docs, found = got, true
+		case len(got) < len(docs):
+			docs = intersectDocs(got, docs)
+		default:
+			docs = intersectDocs(docs, got)
+		}
+	}
+	return docs, found
+}
+
+func (n andNode) cost(x *searchIndex) int {
+	c := math.MaxInt
+	for _, child := range n {
+		c = min(c, child.cost(x))
+	}
+	return c
+}
+
+type orNode []queryNode
+
+func (n orNode) eval(acc *Account, fields []loweredField) (bool, float64) {
+	found, best := false, 0.0
+	for _, child := range n {
+		if ok, score := child.eval(acc, fields); ok {
+			found, best = true, max(best, score)
+		}
+	}
+	return found, best
+}
+
+func (n orNode) plan(x *searchIndex) ([]uint32, bool) {
+	var docs []uint32
+	for _, child := range n {
+		got, ok := child.plan(x)
+		if !ok {
+			return nil, false
+		}
+		docs = unionDocs(docs, got)
+	}
+	return docs, true
+}
+
+func (n orNode) cost(x *searchIndex) int {
+	c := 0
+	for _, child := range n {
+		if c += child.cost(x); c < 0 {
+			return math.MaxInt
+		}
+	}
+	return c
+}
+
+type notNode struct{ child queryNode }
+
+func (n notNode) eval(acc *Account, fields []loweredField) (bool, float64) {
+	ok, _ := n.child.eval(acc, fields)
+	return !ok, 0
+}
+
+func (n notNode) plan(*searchIndex) ([]uint32, bool) {
+	return nil, false
+}
+
+func (n notNode) cost(*searchIndex) int {
+	return math.MaxInt
+}
+
+// termNode — слово или фраза, возможно с указанием поля
+type termNode struct {
+	field string // пусто — любое открытое поле
+	value string // в нижнем регистре
+	exact bool   // без опечаток — см. MatchesExactly
+}
+
+// Веса полей при поиске без указания поля
+const (
+	weightName  = 3
+	weightLogin = 2
+	weightURL   = 2
+	weightTag   = 1.5
+	weightOther = 1
+)
+
+func (n termNode) eval(acc *Account, fields []loweredField) (bool, float64) {
+	var score float64
+	switch n.field {
+	case "":
+		score = n.matchFields(fields)
+	case "name":
+		score = weightName * n.matchField(fields)
+	case "login":
+		score = weightLogin * n.matchField(fields)
+	case "url":
+		score = weightURL * n.matchField(fields)
+	case "tag":
+		score = weightTag * n.matchField(fields)
+	case "folder":
+		if InFolder(acc.Folder, NormalizeFolder(n.value)) {
+			score = weightTag
+		} else {
+			score = weightTag * n.match(acc.Folder)
+		}
+	case "type":
+		if t, ok := ParseItemType(n.value); ok && acc.Kind() == t {
+			score = weightOther
+		}
+	case "has":
+		if acc.has(n.value) {
+			score = weightOther
+		}
+	}
+	return score > 0, score
+}
+
+// matchFields возвращает лучшую оценку среди открытых полей. Сначала
+// поля сравниваются без опечаток — это дёшево, — затем с опечатками, но
+// только те, где слово с опечаткой может перебить найденное: оно стоит
+// не больше typoScoreMax от веса поля. Поля идут по убыванию веса, поэтому
+// оба прохода останавливаются, как только лучшее уже не перебить.
+func (n termNode) matchFields(fields []loweredField) float64 {
+	score, seen := 0.0, len(fields)
+	for i, f := range fields {
+		if score >= f.weight {
+			seen = i
+			break
+		}
+		score = max(score, f.weight*matchLower(f.text, n.value))
+	}
+	if n.exact {
+		return score
+	}
+	for _, f := range fields[:seen] {
+		if score >= typoScoreMax*f.weight {
+			break
+		}
+		score = max(score, f.weight*fuzzyWords(f.text, n.value))
+	}
+	return score
+}
+
+// plan сужает поиск по текстовым полям: подходят документы с подстрокой
+// и, если слово достаточно длинное, со словами на расстоянии опечаток.
+// Папки не сужаются: folder: сравнивает нормализованный путь.
+func (n termNode) plan(x *searchIndex) ([]uint32, bool) {
+	if !n.indexed() {
+		return nil, false
+	}
+	docs := x.lookup(n.value)
+	if limit := typoLimit(len([]rune(n.value))); limit > 0 && !strings.ContainsRune(n.value, ' ') {
+		docs = unionDocs(docs, x.lookupFuzzy(n.value, limit))
+	}
+	return docs, true
+}
+
+func (n termNode) cost(x *searchIndex) int {
+	if !n.indexed() {
+		return math.MaxInt
+	}
+	return x.estimate(n.value)
+}
+
+func (n termNode) indexed() bool {
+	switch n.field {
+	case "", "name", "login", "url", "tag":
+		return true
+	}
+	return false
+}
+
+// has проверяет условие has:что-то
+func (acc *Account) has(what string) bool {
+	switch what {
+	case "otp", "2fa", "totp":
+		return acc.OTP != nil
+	case "attachment", "attachments", "file", "files":
+		return len(acc.Attachments) > 0
+	case "notes", "note":
+		return acc.Notes != ""
+	case "fields", "field":
+		return len(acc.Fields) > 0
+	case "favorite", "fav":
+		return acc.Favorite
+	case "expiry", "expires":
+		return acc.ExpiresAt != nil || acc.MaxAgeDays > 0
+	default:
+		return false
+	}
+}
+
+// queryFields — поля, которые можно указать перед двоеточием
+var queryFields = map[string]string{
+	"name": "name", "имя": "name",
+	"login": "login", "логин": "login",
+	"url": "url", "адрес": "url",
+	"tag": "tag", "тег": "tag",
+	"folder": "folder", "папка": "folder",
+	"type": "type", "вид": "type",
+	"has": "has", "есть": "has",
+}
+
+// match оценивает одно поле записи
+func (n termNode) match(text string) float64 {
+	return matchText(text, n.value, !n.exact)
+}
+
+// matchField возвращает лучшую оценку среди значений поля запроса:
+// адресов с их хостами, тегов
+func (n termNode) matchField(fields []loweredField) float64 {
+	best := 0.0
+	for _, f := range fields {
+		if f.field != n.field {
+			continue
+		}
+		score := matchLower(f.text, n.value)
+		if score == 0 && !n.exact {
+			score = fuzzyWords(f.text, n.value)
+		}
+		best = max(best, score)
+	}
+	return best
+}
+
+// matchText оценивает, насколько text подходит под запрос q (в нижнем
+// регистре): 1 — точное совпадение, меньше — начало, подстрока, слово
+// с опечатками, если typos; 0 — не подходит
+func matchText(text, q string, typos bool) float64 {
+	if text == "" || q == "" {
+		return 0
+	}
+	t := strings.ToLower(text)
+	if score := matchLower(t, q); score > 0 || !typos {
+		return score
+	}
+	return fuzzyWords(t, q)
+}
+
+// matchLower — часть matchText без опечаток для текста в нижнем регистре
+func matchLower(t, q string) float64 {
+	if t == "" || q == "" {
+		return 0
+	}
+	switch {
+	case t == q:
+		return 1
+	case strings.HasPrefix(t, q):
+		return 0.9
+	}
+	if i := strings.Index(t, q); i >= 0 {
+		if isWordStart(t, i) {
+			return 0.8
+		}
+		return 0.7
+	}
+	return 0
+}
+
+// Оценка слова с опечатками: typoScore минус typoPenalty за каждую правку.
+// Без правок слово нашлось бы как подстрока, поэтому больше typoScoreMax
+// такая оценка не бывает.
+const (
+	typoScore    = 0.5
+	typoPenalty  = 0.15
+	typoScoreMax = typoScore - typoPenalty
+)
+
+// fuzzyWords ищет слово текста, отличающееся от запроса не больше чем
+// на допустимое число правок. Начало длинного слова тоже считается:
+// «gogle» находит «googlemail».
+func fuzzyWords(t, q string) float64 {
+	var qbuf, wbuf [32]rune
+	qr := appendRunes(qbuf[:0], q)
+	limit := typoLimit(len(qr))
+	if limit == 0 || strings.ContainsRune(q, ' ') {
+		return 0
+	}
+	best := limit + 1
+	for t != "" && best > 0 {
+		// Слова — непрерывные последовательности букв и цифр
+		start := strings.IndexFunc(t, isWordRune)
+		if start < 0 {
+			break
+		}
+		t = t[start:]
+		end := strings.IndexFunc(t, func(r rune) bool { return !isWordRune(r) })
+		if end < 0 {
+			end = len(t)
+		}
+		word := t[:end]
+		t = t[end:]
+
+		n := utf8.RuneCountInString(word)
+		if abs(n-len(qr)) > limit && n <= len(qr) {
+			continue
+		}
+		best = min(best, wordDistance(qr, appendRunes(wbuf[:0], word), limit))
+	}
+	if best > limit {
+		return 0
+	}
+	return typoScore - typoPenalty*float64(best)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// appendRunes — []rune(s) без выделения памяти, если хватает буфера
+func appendRunes(buf []rune, s string) []rune {
+	for _, r := range s {
+		buf = append(buf, r)
+	}
+	return buf
+}
+
+// typoLimit — сколько опечаток допускается в слове такой длины
+func typoLimit(n int) int {
+	switch {
+	case n < 4:
+		return 0
+	case n < 7:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// wordDistance — расстояние Дамерау — Левенштейна (с перестановкой
+// соседних букв) от q до слова w целиком, если их длины отличаются не
+// больше чем на limit, или до начала w длины len(q) — что меньше. Оба
+// берутся из одной таблицы, а считается в ней только полоса |i-j| <= limit:
+// дальше от диагонали расстояние заведомо больше limit. Как только оно
+// больше limit, возвращается limit+1.
+func wordDistance(q, w []rune, limit int) int {
+	m, over := len(q), limit+1
+	b := w[:min(len(w), m+limit)]
+	n := len(b)
+	var buf [3 * 33]int
+	rows := buf[:]
+	if 3*(n+1) > len(buf) {
+		rows = make([]int, 3*(n+1))
+	}
+	prev2, prev, cur := rows[:n+1], rows[n+1:2*(n+1)], rows[2*(n+1):3*(n+1)]
+	for j := range prev {
+		prev[j] = min(j, over)
+	}
+	for i := 1; i <= m; i++ {
+		lo, hi := max(1, i-limit), min(n, i+limit)
+		cur[0] = min(i, over)
+		if lo > 1 && lo <= n+1 {
+			cur[lo-1] = over
+		}
+		rowMin := cur[0]
+		for j := lo; j <= hi; j++ {
+			cost := 1
+			if q[i-1] == b[j-1] {
+				cost = 0
+			}
+			v := min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && q[i-1] == b[j-2] && q[i-2] == b[j-1] {
+				v = min(v, prev2[j-2]+1)
+			}
+			cur[j] = min(v, over)
+			rowMin = min(rowMin, cur[j])
+		}
+		if hi < n {
+			cur[hi+1] = over
+		}
+		if rowMin > limit {
+			return over
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	best := over
+	if abs(len(w)-m) <= limit {
+		best = prev[len(w)]
+	}
+	if len(w) > m {
+		best = min(best, prev[m])
+	}
+	return best
+}
+
+func isWordStart(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r := []rune(s[:i])
+	last := r[len(r)-1]
+	return !unicode.IsLetter(last) && !unicode.IsDigit(last)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Разбор запроса
+
+type tokenKind int
+
+const (
+	tokTerm tokenKind = iota
+	tokAnd
+	tokOr
+	tokNot
+	tokOpen
+	tokClose
+)
+
+type queryToken struct {
+	kind  tokenKind
+	text  string // исходный текст — для сообщений об ошибках
+	field string
+	value string
+}
+
+func lexQuery(q string) ([]queryToken, error) {
+	var tokens []queryToken
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokOpen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokClose, text: ")"})
+			i++
+		case r == '|':
+			tokens = append(tokens, queryToken{kind: tokOr, text: "|"})
+			i++
+		case r == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]):
+			tokens = append(tokens, queryToken{kind: tokNot, text: "-"})
+			i++
+		default:
+			start := i
+			word, next, err := readWord(rs, i)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+			text := string(rs[start:i])
+			switch word {
+			case "AND", "И":
+				tokens = append(tokens, queryToken{kind: tokAnd, text: text})
+				continue
+			case "OR", "ИЛИ":
+				tokens = append(tokens, queryToken{kind: tokOr, text: text})
+				continue
+			case "NOT", "НЕ":
+				tokens = append(tokens, queryToken{kind: tokNot, text: text})
+				continue
+			}
+
+			tok := queryToken{kind: tokTerm, text: text, value: word}
+			// Неизвестное имя поля — часть слова: так ищутся адреса вида https://…
+			name, rest, ok := strings.Cut(word, ":")
+			field, known := queryFields[strings.ToLower(name)]
+			if ok && known && rs[start] != '"' {
+				tok.field, tok.value = field, rest
+				// Значение в кавычках: name:"мой банк"
+				if rest == "" && i < len(rs) && rs[i] == '"' {
+					valueStart := i
+					if tok.value, i, err = readWord(rs, i); err != nil {
+						return nil, err
+					}
+					tok.text += string(rs[valueStart:i])
+				}
+				if tok.value == "" {
+					return nil, fmt.Errorf("%w: пустое значение после %q", ErrQuerySyntax, name+":")
+				}
+			}
+			tok.value = strings.ToLower(tok.value)
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens, nil
+}
+
+// readWord читает слово до пробела или скобки либо фразу в кавычках
+func readWord(rs []rune, i int) (string, int, error) {
+	if rs[i] == '"' {
+		end := i + 1
+		for end < len(rs) && rs[end] != '"' {
+			end++
+		}
+		if end == len(rs) {
+			return "", 0, fmt.Errorf("%w: не закрыта кавычка", ErrQuerySyntax)
+		}
+		return string(rs[i+1 : end]), end + 1, nil
+	}
+	start := i
+	for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' && rs[i] != '"' {
+		i++
+	}
+	return string(rs[start:i]), i, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// parseOr: and { OR and }
+func (p *queryParser) parseOr() (queryNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := orNode{first}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokOr {
+			break
+		}
+		p.pos++
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, next)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return nodes, nil
+}
+
+// parseAnd: unary { [AND] unary }
+func (p *queryParser) parseAnd() (queryNode, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := andNode{first}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind == tokOr || tok.kind == tokClose {
+			break
+		}
+		if tok.kind == tokAnd {
+			p.pos++
+		}
+		next, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, next)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return nodes, nil
+}
+
+// parseUnary: NOT unary | ( or ) | term
+func (p *queryParser) parseUnary() (queryNode, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("%w: запрос оборван", ErrQuerySyntax)
+	}
+	p.pos++
+	switch tok.kind {
+	case tokNot:
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{child}, nil
+	case tokOpen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || next.kind != tokClose {
+			return nil, fmt.Errorf("%w: не закрыта скобка", ErrQuerySyntax)
+		}
+		p.pos++
+		return node, nil
+	case tokTerm:
+		return termNode{field: tok.field, value: tok.value}, nil
+	default:
+		return nil, fmt.Errorf("%w: неожиданное %q", ErrQuerySyntax, tok.text)
+	}
+}
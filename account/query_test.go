@@ -0,0 +1,34 @@
+package account
+
+import "testing"
+
+func TestMatchesExactly(t *testing.T) {
+	acc := Account{Name: "Google", Login: "alice@gmail.com", Tags: []string{"work"},
+		URIs: []URI{{URL: "https://accounts.google.com"}}}
+	tests := []struct {
+		q     string
+		found bool // Query находит запись
+		exact bool // и без опечаток
+	}{
+		{"google", true, true},
+		{"goo", true, true},
+		{"gogle", true, false},
+		{"name:gogle", true, false},
+		{"alice tag:work", true, true},
+		{"alice tag:wrok", true, false},
+		{"gogle OR alice", true, true},
+		{"-facebook", true, true},
+		{"", true, true},
+		{"(", false, true}, // ошибка в запросе: FindAccount ищет подстрокой
+	}
+	for _, tt := range tests {
+		if node, err := parseQuery(tt.q); err == nil && node != nil {
+			if ok, _ := node.eval(&acc, openFields(acc)); ok != tt.found {
+				t.Errorf("eval(%q) = %v, ожидалось %v", tt.q, ok, tt.found)
+			}
+		}
+		if got := acc.MatchesExactly(tt.q); got != tt.exact {
+			t.Errorf("MatchesExactly(%q) = %v, ожидалось %v", tt.q, got, tt.exact)
+		}
+	}
+}
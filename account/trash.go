@@ -0,0 +1,97 @@
+package account
+
+import "time"
+
+// DefaultTrashRetentionDays — сколько дней удалённые записи лежат в корзине
+const DefaultTrashRetentionDays = 30
+
+// TrashRetention возвращает срок хранения записей в корзине
+func (s VaultSettings) TrashRetention() time.Duration {
+	days := s.TrashRetentionDays
+	if days <= 0 {
+		days = DefaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Trashed возвращает записи из корзины
+func (v *VaultWithDb) Trashed() []Account {
+	v.RLock()
+	defer v.RUnlock()
+	return append([]Account(nil), v.Data.Trash...)
+}
+
+// Restore возвращает запись из корзины в сейф
+func (v *VaultWithDb) Restore(id string) error {
+	v.Lock()
+	defer v.Unlock()
+	i := v.trashIndexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	acc := v.Data.Trash[i]
+	acc.DeletedAt = nil
+	// Запись с тем же ID могла вернуться из резервной копии; вторая копия
+	// делила бы с ней вложения, поэтому восстановление отклоняется
+	if v.indexOf(acc.ID) >= 0 {
+		return ErrDuplicateID
+	}
+	v.Data.Trash = append(v.Data.Trash[:i], v.Data.Trash[i+1:]...)
+	v.Data.Accounts = append(v.Data.Accounts, acc)
+	v.reindexLocked(len(v.Data.Accounts) - 1)
+	v.Data.UpdatedAt = time.Now()
+	return nil
+}
+
+// Purge удаляет запись из корзины навсегда
+func (v *VaultWithDb) Purge(id string) bool {
+	v.Lock()
+	defer v.Unlock()
+	i := v.trashIndexOf(id)
+	if i < 0 {
+		return false
+	}
+	v.dropBlobsLocked(v.Data.Trash[i])
+	v.Data.Trash = append(v.Data.Trash[:i], v.Data.Trash[i+1:]...)
+	v.Data.UpdatedAt = time.Now()
+	return true
+}
+
+// PurgeExpired удаляет из корзины записи старше срока хранения
+// и возвращает их число
+func (v *VaultWithDb) PurgeExpired(now time.Time) int {
+	v.Lock()
+	defer v.Unlock()
+	retention := v.Data.Settings.TrashRetention()
+	var kept []Account
+	for _, acc := range v.Data.Trash {
+		if acc.DeletedAt != nil && now.Sub(*acc.DeletedAt) > retention {
+			v.dropBlobsLocked(acc)
+			continue
+		}
+		kept = append(kept, acc)
+	}
+	purged := len(v.Data.Trash) - len(kept)
+	if purged > 0 {
+		v.Data.Trash = kept
+		v.Data.UpdatedAt = now
+	}
+	return purged
+}
+
+// SetTrashRetention задаёт срок хранения записей в корзине в днях
+func (v *VaultWithDb) SetTrashRetention(days int) {
+	v.Lock()
+	defer v.Unlock()
+	v.Data.Settings.TrashRetentionDays = days
+	v.Data.UpdatedAt = time.Now()
+}
+
+func (v *VaultWithDb) trashIndexOf(id string) int {
+	for i := range v.Data.Trash {
+		if v.Data.Trash[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,40 @@
+package account
+
+import "testing"
+
+// Удалённая запись вернулась из резервной копии: её копия из корзины
+// не должна появиться в сейфе второй раз
+func TestRestoreAfterReplaceKeepsIDsUnique(t *testing.T) {
+	v, _ := testVault(3, 4)
+	backup := append([]Account(nil), v.Data.Accounts...)
+	x := backup[1]
+
+	v.Delete(x.ID)
+	if err := v.Restore(x.ID); err != nil {
+		t.Fatalf("Restore до восстановления копии: %v", err)
+	}
+	v.Delete(x.ID)
+
+	if err := v.ReplaceAccounts(backup); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Data.Trash) != 0 {
+		t.Fatalf("в корзине осталось %d записей с ID из резервной копии", len(v.Data.Trash))
+	}
+	if err := v.Restore(x.ID); err != ErrNotFound {
+		t.Fatalf("Restore после ReplaceAccounts: %v, ожидалась ErrNotFound", err)
+	}
+
+	// Корзина из прежних версий могла уже содержать такую запись
+	v.Data.Trash = append(v.Data.Trash, x)
+	if err := v.Restore(x.ID); err != ErrDuplicateID {
+		t.Fatalf("Restore при живой записи с тем же ID: %v, ожидалась ErrDuplicateID", err)
+	}
+	seen := map[string]bool{}
+	for _, acc := range v.Data.Accounts {
+		if seen[acc.ID] {
+			t.Fatalf("ID %s встречается дважды", acc.ID)
+		}
+		seen[acc.ID] = true
+	}
+}
@@ -0,0 +1,253 @@
+package account
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// URIMatch — правило, по которому адрес записи сравнивается с адресом страницы
+type URIMatch string
+
+const (
+	URIBaseDomain URIMatch = "domain" // совпадает регистрируемый домен: mail.google.com и accounts.google.com
+	URIHost       URIMatch = "host"   // совпадает хост (и порт, если он указан)
+	URIStartsWith URIMatch = "prefix" // та же схема и хост, путь страницы начинается с пути записи
+	URIExact      URIMatch = "exact"  // адреса совпадают целиком
+	URIRegex      URIMatch = "regex"  // адрес страницы подходит под регулярное выражение
+	URINever      URIMatch = "never"  // адрес только для справки, не подставляется
+)
+
+// URIMatches — все правила в порядке показа в меню
+var URIMatches = []URIMatch{URIBaseDomain, URIHost, URIStartsWith, URIExact, URIRegex, URINever}
+
+func (m URIMatch) String() string {
+	switch m {
+	case "", URIBaseDomain:
+		return "домен"
+	case URIHost:
+		return "хост"
+	case URIStartsWith:
+		return "начало адреса"
+	case URIExact:
+		return "точный адрес"
+	case URIRegex:
+		return "регулярное выражение"
+	case URINever:
+		return "не сравнивать"
+	default:
+		return string(m)
+	}
+}
+
+// URI — адрес записи со своим правилом сравнения; пустое правило — по домену
+type URI struct {
+	URL   string   `json:"url"`
+	Match URIMatch `json:"match,omitempty"`
+}
+
+func (u URI) validate() error {
+	switch u.Match {
+	case URIRegex:
+		if _, err := regexp.Compile(u.URL); err != nil {
+			return fmt.Errorf("некорректное регулярное выражение %q", u.URL)
+		}
+		return nil
+	case "", URIBaseDomain, URIHost, URIStartsWith, URIExact, URINever:
+		if _, err := url.ParseRequestURI(u.URL); err != nil {
+			return fmt.Errorf("некорректный URL %q", u.URL)
+		}
+		return nil
+	default:
+		return fmt.Errorf("неизвестное правило сравнения %q", u.Match)
+	}
+}
+
+// Matches сообщает, подходит ли адрес страницы под адрес записи
+func (u URI) Matches(page string) bool {
+	page = strings.TrimSpace(page)
+	if page == "" {
+		return false
+	}
+	switch u.Match {
+	case "", URIBaseDomain:
+		domain := baseDomain(hostOf(page))
+		return domain != "" && baseDomain(hostOf(u.URL)) == domain
+	case URIHost:
+		want, got := hostPort(u.URL), hostPort(page)
+		if want.host == "" || want.host != got.host {
+			return false
+		}
+		return want.port == "" || want.port == got.port
+	case URIStartsWith:
+		return hasURLPrefix(page, u.URL)
+	case URIExact:
+		return normalizeURL(page) == normalizeURL(u.URL)
+	case URIRegex:
+		re, err := regexp.Compile(u.URL)
+		return err == nil && re.MatchString(page)
+	default:
+		return false
+	}
+}
+
+// hasURLPrefix сравнивает схему и хост точно, а путь — целыми сегментами:
+// https://bank.com/app подходит для https://bank.com/app/login, но не для
+// https://bank.com/apple и не для https://bank.com.example.org/app. Если
+// у адреса записи есть параметры запроса, путь должен совпадать целиком,
+// а параметры страницы — начинаться с них.
+func hasURLPrefix(page, prefix string) bool {
+	p, err := url.Parse(strings.TrimSpace(page))
+	if err != nil {
+		return false
+	}
+	want, err := url.Parse(strings.TrimSpace(prefix))
+	if err != nil || want.Host == "" {
+		return false
+	}
+	if !strings.EqualFold(p.Scheme, want.Scheme) || !strings.EqualFold(p.Host, want.Host) {
+		return false
+	}
+	path, wantPath := p.EscapedPath(), want.EscapedPath()
+	if want.RawQuery != "" {
+		return strings.TrimSuffix(path, "/") == strings.TrimSuffix(wantPath, "/") &&
+			(p.RawQuery == want.RawQuery || strings.HasPrefix(p.RawQuery, want.RawQuery+"&"))
+	}
+	switch {
+	case wantPath == "" || wantPath == "/":
+		return true
+	case strings.HasSuffix(wantPath, "/"):
+		return strings.HasPrefix(path, wantPath)
+	default:
+		return path == wantPath || strings.HasPrefix(path, wantPath+"/")
+	}
+}
+
+// PrimaryURL возвращает первый адрес записи — для списков и вывода
+func (acc Account) PrimaryURL() string {
+	if len(acc.URIs) > 0 {
+		return acc.URIs[0].URL
+	}
+	return acc.URL
+}
+
+// MatchesURL сообщает, подходит ли адрес страницы хотя бы под один адрес записи
+func (acc Account) MatchesURL(page string) bool {
+	for _, u := range acc.URIs {
+		if u.Matches(page) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindByURL возвращает записи, которые подходят для страницы page
+// по правилам своих адресов, в порядке RankAccounts
+func (v *VaultWithDb) FindByURL(page string) []Account {
+	v.RLock()
+	defer v.RUnlock()
+	var accounts []Account
+	for _, acc := range v.Data.Accounts {
+		if acc.MatchesURL(page) {
+			accounts = append(accounts, acc)
+		}
+	}
+	RankAccounts(accounts, time.Now())
+	return accounts
+}
+
+// IsPageURL сообщает, что строка поиска — адрес страницы для FindByURL,
+// а не запрос: в ней есть схема и хост, и она не начинается с поля запроса
+// вроде url:https://github.com
+func IsPageURL(s string) bool {
+	if strings.ContainsAny(s, " \t") {
+		return false
+	}
+	if name, _, ok := strings.Cut(s, ":"); ok {
+		if _, known := queryFields[strings.ToLower(name)]; known {
+			return false
+		}
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// ParseURIs разбирает список адресов через запятую; у каждого адреса
+// может быть правило после пробела: "https://example.com host"
+func ParseURIs(s string) ([]URI, error) {
+	var uris []URI
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		u := URI{URL: fields[0]}
+		if len(fields) > 1 {
+			m, ok := ParseURIMatch(fields[1])
+			if !ok {
+				return nil, fmt.Errorf("неизвестное правило сравнения %q", fields[1])
+			}
+			u.Match = m
+		}
+		uris = append(uris, u)
+	}
+	return uris, nil
+}
+
+// FormatURIs записывает адреса в том же виде, в каком их принимает ParseURIs
+func FormatURIs(uris []URI) string {
+	parts := make([]string, len(uris))
+	for i, u := range uris {
+		parts[i] = u.URL
+		if u.Match != "" && u.Match != URIBaseDomain {
+			parts[i] += " " + string(u.Match)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseURIMatch принимает идентификатор правила (host) или его название (хост)
+func ParseURIMatch(s string) (URIMatch, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, m := range URIMatches {
+		if s == string(m) || s == m.String() {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// baseDomain возвращает регистрируемый домен с учётом публичных суффиксов:
+// для mail.google.co.uk это google.co.uk. IP-адреса и одиночные имена
+// вроде localhost возвращаются как есть.
+func baseDomain(host string) string {
+	if host == "" {
+		return ""
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// hostAndPort — хост в нижнем регистре и порт, если он указан явно
+type hostAndPort struct {
+	host, port string
+}
+
+func hostPort(raw string) hostAndPort {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "//" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return hostAndPort{}
+	}
+	return hostAndPort{host: strings.ToLower(u.Hostname()), port: u.Port()}
+}
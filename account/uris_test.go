@@ -0,0 +1,64 @@
+package account
+
+import "testing"
+
+func TestURIMatches(t *testing.T) {
+	tests := []struct {
+		uri  URI
+		page string
+		want bool
+	}{
+		// По домену — с учётом публичных суффиксов
+		{URI{URL: "https://accounts.google.com"}, "https://mail.google.com/inbox", true},
+		{URI{URL: "https://google.com", Match: URIBaseDomain}, "https://GOOGLE.com", true},
+		{URI{URL: "https://www.bbc.co.uk"}, "https://news.bbc.co.uk", true},
+		{URI{URL: "https://www.bbc.co.uk"}, "https://shop.co.uk", false},
+		{URI{URL: "https://alice.github.io"}, "https://bob.github.io", false},
+		{URI{URL: "https://bank.com"}, "https://bank.com.attacker.io", false},
+
+		// По хосту — порт сравнивается, только если указан у записи
+		{URI{URL: "https://intranet.local:8443", Match: URIHost}, "https://intranet.local:8443/wiki", true},
+		{URI{URL: "https://intranet.local:8443", Match: URIHost}, "https://intranet.local/wiki", false},
+		{URI{URL: "https://intranet.local", Match: URIHost}, "http://INTRANET.local:8080", true},
+		{URI{URL: "https://mail.google.com", Match: URIHost}, "https://accounts.google.com", false},
+
+		// По началу адреса — схема и хост точно, путь целыми сегментами
+		{URI{URL: "https://bank.com/", Match: URIStartsWith}, "https://bank.com/login", true},
+		{URI{URL: "https://bank.com/", Match: URIStartsWith}, "https://bank.com", true},
+		{URI{URL: "https://bank.com/", Match: URIStartsWith}, "https://bank.com.attacker.io/login", false},
+		{URI{URL: "https://bank.com", Match: URIStartsWith}, "https://bank.com.attacker.io", false},
+		{URI{URL: "https://bank.com", Match: URIStartsWith}, "https://bank.com@attacker.io/", false},
+		{URI{URL: "https://bank.com/app", Match: URIStartsWith}, "https://bank.com/app", true},
+		{URI{URL: "https://bank.com/app", Match: URIStartsWith}, "https://bank.com/app/", true},
+		{URI{URL: "https://bank.com/app", Match: URIStartsWith}, "https://BANK.com/app/login?x=1", true},
+		{URI{URL: "https://bank.com/app", Match: URIStartsWith}, "https://bank.com/apple-phish", false},
+		{URI{URL: "https://bank.com/app/", Match: URIStartsWith}, "https://bank.com/app", false},
+		{URI{URL: "https://bank.com/app", Match: URIStartsWith}, "http://bank.com/app", false},
+		{URI{URL: "https://bank.com:8443/", Match: URIStartsWith}, "https://bank.com/", false},
+		{URI{URL: "https://bank.com/login?app=1", Match: URIStartsWith}, "https://bank.com/login?app=1&next=/", true},
+		{URI{URL: "https://bank.com/login?app=1", Match: URIStartsWith}, "https://bank.com/login?app=12", false},
+
+		// Точный адрес — без учёта регистра хоста и завершающего "/"
+		{URI{URL: "https://bank.com/login", Match: URIExact}, "https://BANK.com/login/", true},
+		{URI{URL: "https://bank.com/", Match: URIExact}, "https://bank.com", true},
+		{URI{URL: "https://bank.com/login", Match: URIExact}, "https://bank.com/login?next=/", false},
+		{URI{URL: "https://bank.com/login", Match: URIExact}, "https://bank.com/login/2fa", false},
+
+		// Регулярное выражение
+		{URI{URL: `^https://(www\.)?bank\.com/`, Match: URIRegex}, "https://www.bank.com/login", true},
+		{URI{URL: `^https://(www\.)?bank\.com/`, Match: URIRegex}, "https://bank.com.attacker.io/", false},
+		{URI{URL: `([`, Match: URIRegex}, "https://bank.com/", false},
+
+		// Не сравнивать
+		{URI{URL: "https://bank.com", Match: URINever}, "https://bank.com", false},
+
+		// Пустая страница и неизвестное правило
+		{URI{URL: "https://bank.com"}, "", false},
+		{URI{URL: "https://bank.com", Match: "fuzzy"}, "https://bank.com", false},
+	}
+	for _, tt := range tests {
+		if got := tt.uri.Matches(tt.page); got != tt.want {
+			t.Errorf("%s %q для %q = %v, ожидалось %v", tt.uri.Match, tt.uri.URL, tt.page, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package account
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// usageHalfLife — через сколько вес прошлых использований падает вдвое:
+// запись, которую копируют каждый день, обгоняет ту, что открывали
+// много раз месяц назад
+const usageHalfLife = 7 * 24 * time.Hour
+
+// MarkUsed отмечает, что запись использовали: скопировали из неё пароль,
+// код или секрет или открыли её в поиске. Сейф сохраняет вызывающий.
+// Время изменения записи не меняется — это не правка.
+func (v *VaultWithDb) MarkUsed(id string) error {
+	v.Lock()
+	defer v.Unlock()
+	i := v.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	now := time.Now()
+	acc := &v.Data.Accounts[i]
+	acc.UseCount++
+	acc.LastUsedAt = &now
+	v.Data.UpdatedAt = now
+	return nil
+}
+
+// usageScore — число использований с затуханием по давности последнего
+func (acc *Account) usageScore(now time.Time) float64 {
+	if acc.UseCount == 0 || acc.LastUsedAt == nil {
+		return 0
+	}
+	age := max(now.Sub(*acc.LastUsedAt), 0)
+	return float64(acc.UseCount) * math.Exp2(-float64(age)/float64(usageHalfLife))
+}
+
+// RankAccounts упорядочивает записи: сначала избранные, затем часто
+// и недавно используемые; при равенстве сохраняется исходный порядок
+func RankAccounts(accounts []Account, now time.Time) {
+	slices.SortStableFunc(accounts, func(a, b Account) int {
+		return compareUsage(&a, &b, now)
+	})
+}
+
+// compareUsage сравнивает записи по избранному, частоте и давности использования
+func compareUsage(a, b *Account, now time.Time) int {
+	if a.Favorite != b.Favorite {
+		if a.Favorite {
+			return -1
+		}
+		return 1
+	}
+	if sa, sb := a.usageScore(now), b.usageScore(now); sa != sb {
+		if sa > sb {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case a.LastUsedAt == nil && b.LastUsedAt == nil:
+		return 0
+	case a.LastUsedAt == nil:
+		return 1
+	case b.LastUsedAt == nil:
+		return -1
+	default:
+		return b.LastUsedAt.Compare(*a.LastUsedAt)
+	}
+}
@@ -0,0 +1,59 @@
+package account
+
+import (
+	"testing"
+	"time"
+)
+
+// MarkUsed только меняет сейф в памяти; счётчик попадает в хранилище
+// со следующим Save
+func TestMarkUsedDoesNotWrite(t *testing.T) {
+	db := &flakyDb{}
+	v := testVaultWithKey(t, db)
+	acc := Account{ID: NewID(), Type: TypeLogin, Name: "Почта"}
+	v.AddAccount(acc)
+
+	for range 3 {
+		if err := v.MarkUsed(acc.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if db.data != nil {
+		t.Fatalf("MarkUsed записал сейф")
+	}
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenVault(db, db.data, testMasterPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reopened.Get(acc.ID); got.UseCount != 3 || got.LastUsedAt == nil {
+		t.Fatalf("после Save: UseCount = %d, LastUsedAt = %v", got.UseCount, got.LastUsedAt)
+	}
+	if err := v.MarkUsed("нет такой"); err != ErrNotFound {
+		t.Errorf("MarkUsed неизвестной записи: %v", err)
+	}
+}
+
+func TestRankAccounts(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+	accounts := []Account{
+		{Name: "без истории"},
+		{Name: "часто, месяц назад", UseCount: 20, LastUsedAt: ago(30 * 24 * time.Hour)},
+		{Name: "часто, сегодня", UseCount: 10, LastUsedAt: ago(time.Hour)},
+		{Name: "избранное", Favorite: true},
+		{Name: "раз, вчера", UseCount: 1, LastUsedAt: ago(24 * time.Hour)},
+	}
+	RankAccounts(accounts, now)
+	want := []string{"избранное", "часто, сегодня", "часто, месяц назад", "раз, вчера", "без истории"}
+	for i, name := range want {
+		if accounts[i].Name != name {
+			t.Errorf("[%d] = %q, ожидалось %q", i, accounts[i].Name, name)
+		}
+	}
+}
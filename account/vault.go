@@ -2,6 +2,7 @@ package account
 
 import (
 	"encoding/json"
+	"errors"
 	"menedger_paroley/crypto"
 	"menedger_paroley/output"
 	"strings"
@@ -15,73 +16,243 @@ type Db interface {
 }
 
 type Vault struct {
-	Accounts     []Account `json:"accounts"`
-	UpdatedAt    time.Time `json:"updatedAt"`
-	Verification string    `json:"verification"`
+	Accounts  []Account     `json:"accounts"`
+	Trash     []Account     `json:"trash,omitempty"`
+	Settings  VaultSettings `json:"settings"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// VaultSettings — настройки, которые хранятся вместе с сейфом
+type VaultSettings struct {
+	TrashRetentionDays int `json:"trashRetentionDays,omitempty"`
+	PasswordMaxAgeDays int `json:"passwordMaxAgeDays,omitempty"` // 0 — пароли не устаревают
+	ExpiryWarningDays  int `json:"expiryWarningDays,omitempty"`
 }
 
 type VaultWithDb struct {
-	Data Vault
-	Db   Db
+	Data     Vault
+	Db       Db
+	key      *crypto.Key
+	verifier *crypto.Verifier
+	migrated bool
+	otpMu    sync.Mutex // выдача кодов HOTP идёт строго по одному
+
+	staleBlobs []string     // вложения, которые удаляются из BlobStore после Save
+	search     *searchIndex // строится при первом Query
 	sync.RWMutex
 }
 
-func NewVault(db Db) *VaultWithDb {
-	file, err := db.Read()
+var (
+	ErrNoKey         = errors.New("сейф заблокирован: нет ключа шифрования")
+	ErrWrongPassword = errors.New("неверный пароль или повреждённый файл")
+	ErrInvalidFormat = errors.New("ошибка чтения хранилища: неверный формат данных")
+	ErrNotFound      = errors.New("аккаунт не найден")
+	ErrDuplicateID   = errors.New("запись с таким ID уже есть в сейфе")
+)
+
+// NewVault создаёт пустой сейф с ключом из мастер-пароля
+func NewVault(db Db, password string) (*VaultWithDb, error) {
+	key, err := crypto.DeriveKey([]byte(password), crypto.DefaultParams)
 	if err != nil {
-		return &VaultWithDb{
-			Data: Vault{
-				Accounts:  []Account{},
-				UpdatedAt: time.Now(),
-			},
-			Db: db,
-		}
+		return nil, err
 	}
+	return &VaultWithDb{
+		Data: Vault{
+			Accounts:  []Account{},
+			UpdatedAt: time.Now(),
+		},
+		Db:       db,
+		key:      key,
+		verifier: key.Verifier(),
+	}, nil
+}
 
-	var vault Vault
-	err = json.Unmarshal(file, &vault)
+// OpenVault расшифровывает прочитанные из db данные мастер-паролем.
+// Ключ остаётся в сейфе и используется всеми последующими сохранениями.
+// ErrWrongPassword возвращается только при неверном пароле; ошибки
+// заголовка и неподдерживаемый формат возвращаются как есть, чтобы
+// их не считали неудачной попыткой входа.
+func OpenVault(db Db, data []byte, password string) (*VaultWithDb, error) {
+	decrypted, key, err := crypto.OpenWithPassword(data, []byte(password))
+	if errors.Is(err, crypto.ErrWrongPassword) || errors.Is(err, crypto.ErrAuthFailed) {
+		return nil, ErrWrongPassword
+	}
 	if err != nil {
-		output.PrintError("Ошибка чтения хранилища: неверный формат данных")
-		return &VaultWithDb{
-			Data: Vault{
-				Accounts:  []Account{},
-				UpdatedAt: time.Now(),
-			},
-			Db: db,
-		}
+		return nil, err
+	}
+
+	var vault Vault
+	if err := json.Unmarshal(decrypted, &vault); err != nil {
+		return nil, ErrInvalidFormat
 	}
 
 	return &VaultWithDb{
-		Data: vault,
-		Db:   db,
+		Data:     vault,
+		Db:       db,
+		key:      key,
+		verifier: key.Verifier(),
+		migrated: vault.Migrate(),
+	}, nil
+}
+
+// Migrate приводит данные старых версий к текущему формату:
+// выдаёт ID аккаунтам без него, помечает записи без вида как логины
+// и переносит единственный URL в список адресов.
+// Возвращает true, если что-то изменилось.
+func (vault *Vault) Migrate() bool {
+	changed := false
+	for i := range vault.Accounts {
+		if vault.Accounts[i].ID == "" {
+			vault.Accounts[i].ID = NewID()
+			changed = true
+		}
+	}
+	for _, list := range [][]Account{vault.Accounts, vault.Trash} {
+		for i := range list {
+			if list[i].Type == "" {
+				list[i].Type = TypeLogin
+				changed = true
+			}
+			if list[i].URL != "" && len(list[i].URIs) == 0 {
+				list[i].URIs = []URI{{URL: list[i].URL}}
+				list[i].URL = ""
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// NeedsSave сообщает, что при открытии сейф был приведён к новому формату
+// и его стоит сохранить
+func (v *VaultWithDb) NeedsSave() bool {
+	v.RLock()
+	defer v.RUnlock()
+	return v.migrated
+}
+
+// Verifier возвращает проверочное значение мастер-пароля сейфа.
+// Оно остаётся доступным и после ClearKey.
+func (v *VaultWithDb) Verifier() *crypto.Verifier {
+	v.RLock()
+	defer v.RUnlock()
+	return v.verifier
+}
+
+// ClearKey затирает ключ сессии; до SetKey сейф нельзя сохранить
+func (v *VaultWithDb) ClearKey() {
+	v.Lock()
+	defer v.Unlock()
+	if v.key != nil {
+		v.key.Wipe()
+		v.key = nil
 	}
 }
 
-func (v *VaultWithDb) DeleteAccountByURL(url string) bool {
+// SetKey возвращает ключ сессии после повторного ввода мастер-пароля
+func (v *VaultWithDb) SetKey(key *crypto.Key) {
 	v.Lock()
 	defer v.Unlock()
-	var accounts []Account
-	isDeleted := false
-	for _, acc := range v.Data.Accounts {
-		isMatched := strings.Contains(strings.ToLower(acc.URL), strings.ToLower(url))
-		if !isMatched {
-			accounts = append(accounts, acc)
-			continue
+	v.key = key
+	v.verifier = key.Verifier()
+}
+
+// HasKey сообщает, разблокирован ли сейф
+func (v *VaultWithDb) HasKey() bool {
+	v.RLock()
+	defer v.RUnlock()
+	return v.key != nil
+}
+
+// Rekey сохраняет сейф с новым ключом; если запись не удалась, остаётся прежний
+func (v *VaultWithDb) Rekey(key *crypto.Key) error {
+	v.Lock()
+	old, oldVerifier := v.key, v.verifier
+	v.key, v.verifier = key, key.Verifier()
+	v.Unlock()
+
+	if err := v.Save(); err != nil {
+		v.Lock()
+		v.key, v.verifier = old, oldVerifier
+		v.Unlock()
+		return err
+	}
+	if old != nil {
+		old.Wipe()
+	}
+	return nil
+}
+
+// Get возвращает аккаунт по ID
+func (v *VaultWithDb) Get(id string) (Account, bool) {
+	v.RLock()
+	defer v.RUnlock()
+	if i := v.indexOf(id); i >= 0 {
+		return v.Data.Accounts[i], true
+	}
+	return Account{}, false
+}
+
+// Delete переносит аккаунт с данным ID в корзину
+func (v *VaultWithDb) Delete(id string) bool {
+	v.Lock()
+	defer v.Unlock()
+	i := v.indexOf(id)
+	if i < 0 {
+		return false
+	}
+	now := time.Now()
+	acc := v.Data.Accounts[i]
+	acc.DeletedAt = &now
+	v.Data.Accounts = append(v.Data.Accounts[:i], v.Data.Accounts[i+1:]...)
+	v.unindexLocked(id, i)
+	v.Data.Trash = append(v.Data.Trash, acc)
+	v.Data.UpdatedAt = now
+	return true
+}
+
+func (v *VaultWithDb) indexOf(id string) int {
+	// Индекс поиска знает место каждой записи; перебор — если он не построен
+	if v.search != nil {
+		if i, ok := v.search.pos[id]; ok && i < len(v.Data.Accounts) && v.Data.Accounts[i].ID == id {
+			return i
 		}
-		isDeleted = true
 	}
-	v.Data.Accounts = accounts
-	v.Data.UpdatedAt = time.Now()
-	return isDeleted
+	for i := range v.Data.Accounts {
+		if v.Data.Accounts[i].ID == id {
+			return i
+		}
+	}
+	return -1
 }
 
 func (v *VaultWithDb) AddAccount(acc Account) {
 	v.Lock()
 	defer v.Unlock()
+	if acc.ID == "" {
+		acc.ID = NewID()
+	}
 	v.Data.Accounts = append(v.Data.Accounts, acc)
+	v.reindexLocked(len(v.Data.Accounts) - 1)
 	v.Data.UpdatedAt = time.Now()
 }
 
+// Update применяет изменения к аккаунту с данным ID и возвращает обновлённую запись
+func (v *VaultWithDb) Update(id string, upd AccountUpdate) (Account, error) {
+	v.Lock()
+	defer v.Unlock()
+	i := v.indexOf(id)
+	if i < 0 {
+		return Account{}, ErrNotFound
+	}
+	if err := v.Data.Accounts[i].Apply(upd); err != nil {
+		return Account{}, err
+	}
+	v.reindexLocked(i)
+	v.Data.UpdatedAt = time.Now()
+	return v.Data.Accounts[i], nil
+}
+
 func (vault *VaultWithDb) ToBytes() ([]byte, error) {
 	vault.RLock()
 	defer vault.RUnlock()
@@ -92,38 +263,84 @@ func (vault *VaultWithDb) ToBytes() ([]byte, error) {
 	return data, nil
 }
 
+// FindAccount ищет записи по запросу на языке Query и возвращает их
+// по убыванию релевантности. Если запрос не разобрался, ищется подстрока.
 func (v *VaultWithDb) FindAccount(query string) []Account {
-	v.RLock()
-	defer v.RUnlock()
-	var accounts []Account
-	q := strings.ToLower(query)
-
-	for _, acc := range v.Data.Accounts {
-		if strings.Contains(strings.ToLower(acc.Name), q) ||
-			strings.Contains(strings.ToLower(acc.Login), q) ||
-			strings.Contains(strings.ToLower(acc.URL), q) {
-			accounts = append(accounts, acc)
+	accounts, _ := v.FindBest(query, 0)
+	return accounts
+}
+
+// FindBest — FindAccount, который возвращает не больше limit лучших записей
+// (0 — все) и общее число найденных
+func (v *VaultWithDb) FindBest(query string, limit int) ([]Account, int) {
+	results, total, err := v.QueryTop(query, limit)
+	if err != nil {
+		accounts := v.Search(Filter{Query: query})
+		total = len(accounts)
+		if limit > 0 && len(accounts) > limit {
+			accounts = accounts[:limit]
 		}
+		return accounts, total
 	}
-	return accounts
+	accounts := make([]Account, len(results))
+	for i, r := range results {
+		accounts[i] = r.Account
+	}
+	return accounts, total
+}
+
+// matches ищет подстроку q (в нижнем регистре) в открытых полях аккаунта;
+// пароль и скрытые поля не просматриваются
+func (acc Account) matches(q string) bool {
+	if strings.Contains(strings.ToLower(acc.Name), q) ||
+		strings.Contains(strings.ToLower(acc.Notes), q) ||
+		strings.Contains(strings.ToLower(acc.Folder), q) {
+		return true
+	}
+	for _, s := range acc.searchText() {
+		if strings.Contains(strings.ToLower(s), q) {
+			return true
+		}
+	}
+	for _, t := range acc.Tags {
+		if strings.Contains(t, q) {
+			return true
+		}
+	}
+	for _, f := range acc.Fields {
+		if strings.Contains(strings.ToLower(f.Name), q) ||
+			f.Searchable() && strings.Contains(strings.ToLower(f.Value), q) {
+			return true
+		}
+	}
+	return false
 }
 
+// Save шифрует сейф ключом сессии и записывает его в хранилище.
+// Шифрование идёт под блокировкой, чтобы ClearKey не затёр ключ на полпути.
 func (vault *VaultWithDb) Save() error {
 	vault.RLock()
+	stale := len(vault.staleBlobs)
 	data, err := json.MarshalIndent(&vault.Data, "", "  ")
-	vault.RUnlock()
 	if err != nil {
+		vault.RUnlock()
 		output.PrintError(err)
 		return err
 	}
-
-	// 🔐 Шифруем данные
-	password := "master" // ← НЕЛЬЗЯ ЖЁСТКО! Нужно получать из вне
-	encrypted, err := crypto.Encrypt(data, []byte(password))
+	if vault.key == nil {
+		vault.RUnlock()
+		return ErrNoKey
+	}
+	encrypted, err := vault.key.Seal(data)
+	vault.RUnlock()
 	if err != nil {
 		output.PrintError("Ошибка шифрования")
 		return err
 	}
 
-	return vault.Db.Write(encrypted)
+	if err := vault.Db.Write(encrypted); err != nil {
+		return err
+	}
+	vault.deleteStaleBlobs(stale)
+	return nil
 }
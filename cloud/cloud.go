@@ -2,12 +2,17 @@ package cloud
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
+// ErrNotFound — сейфа по этому адресу ещё нет (сервер ответил 404)
+var ErrNotFound = errors.New("файл не найден на сервере")
+
 type CloudDb struct {
 	URL      string
 	Username string
@@ -36,6 +41,9 @@ func (db *CloudDb) Read() ([]byte, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("сервер вернул ошибку: %d", resp.StatusCode)
 	}
@@ -70,3 +78,62 @@ func (db *CloudDb) Write(data []byte) error {
 	fmt.Println("Данные успешно сохранены в облаке")
 	return nil
 }
+
+// Вложения хранятся рядом с сейфом: <URL сейфа>.blob.<ID>
+
+func (db *CloudDb) blobURL(id string) string {
+	return db.URL + ".blob." + url.PathEscape(id)
+}
+
+func (db *CloudDb) ReadBlob(id string) ([]byte, error) {
+	resp, err := db.do("GET", db.blobURL(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("сервер вернул ошибку: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (db *CloudDb) WriteBlob(id string, data []byte) error {
+	resp, err := db.do("PUT", db.blobURL(id), data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("сервер вернул: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (db *CloudDb) DeleteBlob(id string) error {
+	resp, err := db.do("DELETE", db.blobURL(id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("сервер вернул: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (db *CloudDb) do(method, target string, body []byte) (*http.Response, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.SetBasicAuth(db.Username, db.Password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса: %w", err)
+	}
+	return resp, nil
+}
@@ -1,35 +1,110 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"menedger_paroley/account"
+	"menedger_paroley/cloud"
+	"menedger_paroley/files"
+	"menedger_paroley/input"
 	"menedger_paroley/internal/app"
 	"menedger_paroley/internal/auth"
 	"menedger_paroley/internal/config"
 	"menedger_paroley/output"
+	"os"
+	"time"
 
 	"github.com/fatih/color"
 )
 
+// attemptsFile хранит счётчик неудачных попыток входа
+const attemptsFile = "attempts.json"
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "change-password" {
+		changePassword(os.Args[2:])
+		return
+	}
+
+	wipeAfter := flag.Int("wipe-after", 0, "стереть сейф после N неудачных попыток подряд (0 — никогда)")
+	idle := flag.Duration("idle", app.DefaultIdleTimeout, "заблокировать сеанс после бездействия (0 — не блокировать)")
+	passwordFd := flag.Int("password-fd", -1, "читать пароли построчно из этого файлового дескриптора")
+	flag.Parse()
+
+	if *passwordFd >= 0 {
+		input.UsePasswordFile(os.NewFile(uintptr(*passwordFd), "password-fd"))
+	}
+
 	color.Cyan("🔒 Менеджер паролей")
 
 	db := config.ChooseStorage()
-	password := app.PromptPassword("Введите мастер-пароль: ")
-
-	vault := app.LoadVault(db, password) // ← передаём пароль
+	guard := newGuard(db, *wipeAfter)
 
-	if len(vault.Data.Accounts) == 0 {
-		err := auth.SetMasterPassword(password)
-		if err != nil {
-			output.PrintError("Ошибка сохранения: " + err.Error())
-			return
+	for {
+		password := input.Password("Введите мастер-пароль: ")
+		if input.EOF() {
+			os.Exit(1)
 		}
-		color.Green("Мастер-пароль установлен")
-	} else {
-		if !auth.Verify(password) {
-			output.PrintError("❌ Неверный пароль")
+		vault, err := app.LoadVault(db, guard, password)
+		if err == nil {
+			app.RunCLI(vault, guard, *idle)
 			return
 		}
+
+		output.PrintError(err)
+		if errors.Is(err, input.ErrMismatch) {
+			continue
+		}
+		var failed *auth.FailedAttemptError
+		if !errors.As(err, &failed) {
+			os.Exit(1)
+		}
+		time.Sleep(failed.Delay)
+	}
+}
+
+func newGuard(db account.Db, wipeAfter int) *auth.Guard {
+	policy := auth.DefaultPolicy
+	policy.WipeAfter = wipeAfter
+	guard := auth.NewGuard(auth.FileStore(attemptsFile), policy)
+	guard.OnWipe(func() error {
+		return db.Write(nil)
+	})
+	return guard
+}
+
+// changePassword меняет мастер-пароль сейфа без меню. Старый пароль и дважды
+// новый читаются из stdin построчно; для сейфа в WebDAV первой строкой
+// идёт пароль WebDAV:
+//
+//	printf '%s\n%s\n%s\n' "$OLD" "$NEW" "$NEW" | passman change-password -vault data.enc
+//	printf '%s\n%s\n%s\n%s\n' "$DAV" "$OLD" "$NEW" "$NEW" | passman change-password -webdav-url https://… -webdav-user me
+func changePassword(args []string) {
+	fs := flag.NewFlagSet("change-password", flag.ExitOnError)
+	path := fs.String("vault", "data.enc", "путь к файлу сейфа")
+	webdavURL := fs.String("webdav-url", "", "адрес сейфа в WebDAV вместо локального файла")
+	webdavUser := fs.String("webdav-user", "", "логин WebDAV")
+	fs.Parse(args)
+
+	var db account.Db = files.NewJsonDb(*path)
+	if *webdavURL != "" {
+		db = cloud.NewCloudDb(*webdavURL, *webdavUser, input.Password("Пароль WebDAV: "))
 	}
 
-	app.RunCLI(vault, password) // ← передаём пароль
+	oldPassword := input.Password("Текущий мастер-пароль: ")
+	newPassword, err := input.NewPassword("Новый мастер-пароль: ")
+	if err == nil {
+		guard := newGuard(db, 0)
+		var vault *account.VaultWithDb
+		// Открытие сейфа уже проверяет старый пароль с учётом счётчика попыток
+		vault, err = app.LoadExistingVault(db, guard, oldPassword)
+		if err == nil {
+			err = app.SetMasterPassword(vault, newPassword)
+		}
+	}
+	if err != nil {
+		output.PrintError(err)
+		os.Exit(1)
+	}
+	color.Green("Мастер-пароль изменён")
 }
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+// DataKeySize — длина ключа отдельного объекта, например вложения
+const DataKeySize = 32
+
+// NewDataKey возвращает случайный ключ для шифрования отдельного объекта.
+// Сам ключ хранится внутри сейфа и защищён ключом сейфа, поэтому после
+// смены мастер-пароля такие объекты не нужно перешифровывать.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SealData шифрует data ключом объекта: nonce | ciphertext.
+// ad привязывает шифротекст к объекту, чтобы его нельзя было подменить другим.
+func SealData(key, data, ad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, ad), nil
+}
+
+// OpenData расшифровывает результат SealData
+func OpenData(key, data, ad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrInvalidData
+	}
+	n := gcm.NonceSize()
+	return gcm.Open(nil, data[:n], data[n:], ad)
+}
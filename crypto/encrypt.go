@@ -3,66 +3,106 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/pbkdf2"
 )
 
 const debug = false
 
+// Пределы параметров из заголовка: испорченный или подложенный файл не должен
+// заставить программу выделить гигабайты памяти или считать ключ часами.
+// Они с запасом выше параметров по умолчанию.
+const (
+	maxArgon2Memory     = 1 << 20 // КиБ, то есть 1 ГиБ
+	maxArgon2Passes     = 16
+	maxArgon2Threads    = 64
+	maxPBKDF2Iterations = 10_000_000
+)
+
+var (
+	ErrInvalidData   = errors.New("данные повреждены или слишком короткие")
+	ErrInvalidParams = errors.New("некорректные параметры выработки ключа")
+)
+
 // Encrypt шифрует данные с помощью пароля
 func Encrypt(data, password []byte) ([]byte, error) {
+	return EncryptWithParams(data, password, DefaultParams)
+}
+
+// EncryptWithParams шифрует данные с заданными параметрами и записывает их в заголовок
+func EncryptWithParams(data, password []byte, params Params) ([]byte, error) {
 	if debug {
 		fmt.Println("🔐 DEBUG: Начало шифрования")
 	}
 	os.Stdout.Sync()
 
-	salt := make([]byte, 32)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+	key, err := DeriveKey(password, params)
+	if err != nil {
 		return nil, err
 	}
+	return key.Seal(data)
+}
 
-	key := pbkdf2.Key(password, salt, 100000, 32, sha256.New)
+// Decrypt расшифровывает данные с помощью пароля
+func Decrypt(data, password []byte) ([]byte, error) {
+	plain, _, err := open(data, password)
+	return plain, err
+}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+// decryptLegacy читает формат salt||nonce||ciphertext без заголовка
+func decryptLegacy(data, password []byte) ([]byte, error) {
+	saltSize, nonceSize := PBKDF2Params.SaltLen, 12
+	if len(data) < saltSize+nonceSize {
+		return nil, ErrInvalidData
 	}
+	salt := data[:saltSize]
+	nonce, ciphertext := data[saltSize:saltSize+nonceSize], data[saltSize+nonceSize:]
 
-	gcm, err := cipher.NewGCM(block)
+	key, err := deriveKey(password, salt, PBKDF2Params)
 	if err != nil {
 		return nil, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	gcm, err := newGCM(key)
+	if err != nil {
 		return nil, err
 	}
 
-	ciphertext := gcm.Seal(nil, nonce, data, nil)
-	return append(append(salt, nonce...), ciphertext...), nil
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+	return plain, nil
 }
 
-// Decrypt расшифровывает данные с помощью пароля
-func Decrypt(data, password []byte) ([]byte, error) {
-	salt, nonceSize := data[:32], 12
-	nonce, ciphertext := data[32:32+nonceSize], data[32+nonceSize:]
-
-	key := pbkdf2.Key(password, salt, 100000, 32, sha256.New)
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+func deriveKey(password, salt []byte, params Params) ([]byte, error) {
+	switch params.KDF {
+	case KDFPBKDF2:
+		if params.Iterations == 0 || params.Iterations > maxPBKDF2Iterations {
+			return nil, ErrInvalidParams
+		}
+		return pbkdf2.Key(password, salt, int(params.Iterations), 32, sha256.New), nil
+	case KDFArgon2id:
+		if params.Iterations == 0 || params.Iterations > maxArgon2Passes ||
+			params.Threads == 0 || params.Threads > maxArgon2Threads ||
+			params.Memory < 8*uint32(params.Threads) || params.Memory > maxArgon2Memory {
+			return nil, ErrInvalidParams
+		}
+		return argon2.IDKey(password, salt, params.Iterations, params.Memory, params.Threads, 32), nil
+	default:
+		return nil, ErrUnsupportedKDF
 	}
+}
 
-	gcm, err := cipher.NewGCM(block)
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	return cipher.NewGCM(block)
 }
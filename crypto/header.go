@@ -0,0 +1,208 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Формат зашифрованного блока:
+//
+//	magic "PMAN" | version | kdf | len(params) | params | len(salt) | salt |
+//	len(check) | check | cipher | len(nonce) | nonce | ciphertext
+//
+// Поле check появилось во второй версии: это проверочное значение ключа,
+// по которому пароль можно проверить, не расшифровывая данные.
+//
+// Заголовок целиком передаётся в AES-GCM как дополнительные данные,
+// поэтому подменить параметры без повреждения тега нельзя.
+
+var magic = []byte("PMAN")
+
+const headerVersion = 2
+
+// KDF — идентификатор функции выработки ключа
+type KDF byte
+
+const (
+	KDFPBKDF2   KDF = 1
+	KDFArgon2id KDF = 2
+)
+
+// Cipher — идентификатор алгоритма шифрования
+type Cipher byte
+
+const (
+	CipherAES256GCM Cipher = 1
+)
+
+var (
+	ErrInvalidHeader      = errors.New("повреждённый заголовок данных")
+	ErrUnsupportedVersion = errors.New("неподдерживаемая версия формата")
+	ErrUnsupportedKDF     = errors.New("неподдерживаемая функция выработки ключа")
+	ErrUnsupportedCipher  = errors.New("неподдерживаемый алгоритм шифрования")
+)
+
+// Params — параметры выработки ключа и шифрования
+type Params struct {
+	KDF        KDF
+	Iterations uint32 // PBKDF2 — число итераций, Argon2id — число проходов
+	Memory     uint32 // Argon2id — объём памяти в КиБ
+	Threads    uint8  // Argon2id — степень параллелизма
+	SaltLen    int
+	Cipher     Cipher
+}
+
+// DefaultParams — параметры для новых данных
+var DefaultParams = Argon2idParams
+
+// Argon2idParams — Argon2id с 64 МиБ памяти, 3 проходами и 4 потоками
+var Argon2idParams = Params{
+	KDF:        KDFArgon2id,
+	Iterations: 3,
+	Memory:     64 * 1024,
+	Threads:    4,
+	SaltLen:    32,
+	Cipher:     CipherAES256GCM,
+}
+
+// PBKDF2Params — PBKDF2-HMAC-SHA256 со 100 000 итераций, как у файлов без заголовка
+var PBKDF2Params = Params{
+	KDF:        KDFPBKDF2,
+	Iterations: 100000,
+	SaltLen:    32,
+	Cipher:     CipherAES256GCM,
+}
+
+type header struct {
+	Version byte
+	Params  Params
+	Salt    []byte
+	Check   []byte
+	Nonce   []byte
+}
+
+func hasHeader(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+func (p Params) marshalKDF() ([]byte, error) {
+	switch p.KDF {
+	case KDFPBKDF2:
+		return binary.BigEndian.AppendUint32(nil, p.Iterations), nil
+	case KDFArgon2id:
+		b := binary.BigEndian.AppendUint32(nil, p.Iterations)
+		b = binary.BigEndian.AppendUint32(b, p.Memory)
+		return append(b, p.Threads), nil
+	default:
+		return nil, ErrUnsupportedKDF
+	}
+}
+
+func (p *Params) unmarshalKDF(b []byte) error {
+	switch p.KDF {
+	case KDFPBKDF2:
+		if len(b) != 4 {
+			return ErrInvalidHeader
+		}
+		p.Iterations = binary.BigEndian.Uint32(b)
+		return nil
+	case KDFArgon2id:
+		if len(b) != 9 {
+			return ErrInvalidHeader
+		}
+		p.Iterations = binary.BigEndian.Uint32(b[0:4])
+		p.Memory = binary.BigEndian.Uint32(b[4:8])
+		p.Threads = b[8]
+		return nil
+	default:
+		return ErrUnsupportedKDF
+	}
+}
+
+func (h header) marshal() ([]byte, error) {
+	kdfParams, err := h.Params.marshalKDF()
+	if err != nil {
+		return nil, err
+	}
+	if len(kdfParams) > 255 || len(h.Salt) > 255 || len(h.Check) > 255 || len(h.Nonce) > 255 {
+		return nil, ErrInvalidHeader
+	}
+
+	buf := append([]byte{}, magic...)
+	buf = append(buf, h.Version, byte(h.Params.KDF), byte(len(kdfParams)))
+	buf = append(buf, kdfParams...)
+	buf = append(buf, byte(len(h.Salt)))
+	buf = append(buf, h.Salt...)
+	if h.Version >= 2 {
+		buf = append(buf, byte(len(h.Check)))
+		buf = append(buf, h.Check...)
+	}
+	buf = append(buf, byte(h.Params.Cipher), byte(len(h.Nonce)))
+	buf = append(buf, h.Nonce...)
+	return buf, nil
+}
+
+// parseHeader разбирает заголовок и возвращает его вместе с сырыми байтами
+// заголовка и оставшимся шифротекстом
+func parseHeader(data []byte) (header, []byte, []byte, error) {
+	var h header
+	r := &reader{buf: data}
+
+	if !bytes.Equal(r.next(len(magic)), magic) {
+		return h, nil, nil, ErrInvalidHeader
+	}
+	h.Version = r.byte()
+	if r.err == nil && (h.Version < 1 || h.Version > headerVersion) {
+		return h, nil, nil, ErrUnsupportedVersion
+	}
+
+	h.Params.KDF = KDF(r.byte())
+	kdfParams := r.next(int(r.byte()))
+	h.Salt = r.next(int(r.byte()))
+	h.Params.SaltLen = len(h.Salt)
+	if h.Version >= 2 {
+		h.Check = r.next(int(r.byte()))
+	}
+	h.Params.Cipher = Cipher(r.byte())
+	h.Nonce = r.next(int(r.byte()))
+	if r.err != nil {
+		return h, nil, nil, r.err
+	}
+
+	if err := h.Params.unmarshalKDF(kdfParams); err != nil {
+		return h, nil, nil, err
+	}
+	if h.Params.Cipher != CipherAES256GCM {
+		return h, nil, nil, ErrUnsupportedCipher
+	}
+
+	return h, data[:r.pos], data[r.pos:], nil
+}
+
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.pos+n > len(r.buf) {
+		r.err = ErrInvalidHeader
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// sealLegacy записывает данные в формате первых версий: salt | nonce | ciphertext
+func sealLegacy(t *testing.T, password string, plain []byte) []byte {
+	t.Helper()
+	salt := make([]byte, PBKDF2Params.SaltLen)
+	nonce := make([]byte, 12)
+	rand.Read(salt)
+	rand.Read(nonce)
+	key, err := deriveKey([]byte(password), salt, PBKDF2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(bytes.Clone(salt), nonce...)
+	return gcm.Seal(data, nonce, plain, nil)
+}
+
+// sealV1 записывает данные с заголовком первой версии — без проверочного значения
+func sealV1(t *testing.T, password string, plain []byte) []byte {
+	t.Helper()
+	salt := make([]byte, testParams.SaltLen)
+	nonce := make([]byte, 12)
+	rand.Read(salt)
+	rand.Read(nonce)
+	key, err := deriveKey([]byte(password), salt, testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr, err := header{Version: 1, Params: testParams, Salt: salt, Nonce: nonce}.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gcm.Seal(hdr, nonce, plain, hdr)
+}
+
+func TestOpenLegacyWithoutHeader(t *testing.T) {
+	plain := []byte(`{"accounts":[]}`)
+	data := sealLegacy(t, "пароль", plain)
+
+	got, key, err := OpenWithPassword(data, []byte("пароль"))
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("OpenWithPassword = %q, %v", got, err)
+	}
+	// Файл без заголовка переводится на новый формат при следующем сохранении
+	if key.params != DefaultParams {
+		t.Errorf("ключ для старого файла выработан с %+v, ожидались параметры по умолчанию", key.params)
+	}
+	if _, err := ReadVerifier(data); !errors.Is(err, ErrNoVerifier) {
+		t.Errorf("ReadVerifier без заголовка: %v", err)
+	}
+
+	if _, _, err := OpenWithPassword(data, []byte("неверный")); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("неверный пароль: %v, ожидалась ErrAuthFailed", err)
+	}
+	if _, _, err := OpenWithPassword(data[:20], []byte("пароль")); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("слишком короткий файл: %v, ожидалась ErrInvalidData", err)
+	}
+}
+
+func TestOpenV1HeaderWithoutCheck(t *testing.T) {
+	plain := []byte("данные")
+	data := sealV1(t, "пароль", plain)
+
+	h, _, _, err := parseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != 1 || h.Check != nil {
+		t.Fatalf("заголовок %+v, ожидалась версия 1 без проверочного значения", h)
+	}
+
+	got, key, err := OpenWithPassword(data, []byte("пароль"))
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("OpenWithPassword = %q, %v", got, err)
+	}
+	// Без проверочного значения неверный пароль обнаруживает только GCM
+	if _, _, err := OpenWithPassword(data, []byte("неверный")); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("неверный пароль: %v, ожидалась ErrAuthFailed", err)
+	}
+
+	// После пересохранения заголовок получает проверочное значение
+	again, err := key.Seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := ReadVerifier(again)
+	if err != nil {
+		t.Fatalf("ReadVerifier после пересохранения: %v", err)
+	}
+	if !v.Verify([]byte("пароль")) || v.Verify([]byte("неверный")) {
+		t.Errorf("Verifier после пересохранения проверяет пароль неверно")
+	}
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+	for _, params := range []Params{PBKDF2Params, Argon2idParams} {
+		h := header{
+			Version: headerVersion,
+			Params:  params,
+			Salt:    bytes.Repeat([]byte{1}, params.SaltLen),
+			Check:   bytes.Repeat([]byte{2}, 16),
+			Nonce:   bytes.Repeat([]byte{3}, 12),
+		}
+		hdr, err := h.marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, raw, rest, err := parseHeader(append(hdr, "шифротекст"...))
+		if err != nil {
+			t.Fatalf("%v: %v", params.KDF, err)
+		}
+		if got.Params != params || !bytes.Equal(got.Salt, h.Salt) || !bytes.Equal(got.Check, h.Check) ||
+			!bytes.Equal(got.Nonce, h.Nonce) || !bytes.Equal(raw, hdr) || string(rest) != "шифротекст" {
+			t.Errorf("%v: разобрано %+v", params.KDF, got)
+		}
+	}
+}
+
+func TestDeriveKeyRejectsExcessiveParams(t *testing.T) {
+	salt := make([]byte, 16)
+	for _, params := range []Params{
+		{KDF: KDFPBKDF2, Iterations: 0},
+		{KDF: KDFPBKDF2, Iterations: 1 << 31},
+		{KDF: KDFArgon2id, Iterations: 1 << 31, Memory: 64 * 1024, Threads: 4},
+		{KDF: KDFArgon2id, Iterations: 3, Memory: 1 << 30, Threads: 4},
+		{KDF: KDFArgon2id, Iterations: 3, Memory: 64 * 1024, Threads: 255},
+		{KDF: KDFArgon2id, Iterations: 3, Memory: 64 * 1024, Threads: 0},
+	} {
+		if _, err := deriveKey([]byte("пароль"), salt, params); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%+v: %v, ожидалась ErrInvalidParams", params, err)
+		}
+	}
+}
+
+func TestOpenRejectsHeaderWithHugeIterations(t *testing.T) {
+	_, data := sealTest(t, "пароль", []byte("данные"))
+	h, hdr, ciphertext, err := parseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Params.Iterations = 1<<32 - 1
+	bad, err := h.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bad) != len(hdr) {
+		t.Fatalf("длина заголовка изменилась")
+	}
+	// Без предела выработка ключа заняла бы часы
+	if _, _, err := OpenWithPassword(append(bad, ciphertext...), []byte("пароль")); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("OpenWithPassword: %v, ожидалась ErrInvalidParams", err)
+	}
+}
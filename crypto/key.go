@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var (
+	ErrKeyMismatch   = errors.New("данные зашифрованы другим ключом")
+	ErrWrongPassword = errors.New("неверный пароль")
+	ErrKeyWiped      = errors.New("ключ затёрт")
+	// ErrAuthFailed — шифротекст не прошёл проверку GCM: ключ не тот
+	// (для ключа из пароля — неверный пароль) или данные испорчены
+	ErrAuthFailed = errors.New("данные не прошли проверку подлинности")
+)
+
+// Key — ключ, выработанный из пароля один раз на сессию.
+// Seal и Open используют его повторно, меняя только nonce.
+type Key struct {
+	params Params
+	salt   []byte
+	key    []byte
+	check  []byte
+}
+
+// DeriveKey вырабатывает ключ из пароля со свежей солью
+func DeriveKey(password []byte, params Params) (*Key, error) {
+	salt := make([]byte, params.SaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return newKey(password, salt, params)
+}
+
+func newKey(password, salt []byte, params Params) (*Key, error) {
+	if params.Cipher != CipherAES256GCM {
+		return nil, ErrUnsupportedCipher
+	}
+	key, err := deriveKey(password, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{params: params, salt: salt, key: key, check: checkValue(key)}, nil
+}
+
+// OpenWithPassword расшифровывает данные паролем и возвращает ключ сессии.
+// Для файлов без заголовка ключ вырабатывается заново с параметрами
+// по умолчанию, так что следующее сохранение переведёт их на новый формат.
+func OpenWithPassword(data, password []byte) ([]byte, *Key, error) {
+	plain, key, err := open(data, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil {
+		key, err = DeriveKey(password, DefaultParams)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return plain, key, nil
+}
+
+// open расшифровывает данные паролем; для файлов без заголовка ключ не возвращается
+func open(data, password []byte) ([]byte, *Key, error) {
+	if !hasHeader(data) {
+		plain, err := decryptLegacy(data, password)
+		return plain, nil, err
+	}
+
+	h, _, _, err := parseHeader(data)
+	if err != nil {
+		// Старый файл без заголовка может случайно начинаться с "PMAN"
+		if plain, legacyErr := decryptLegacy(data, password); legacyErr == nil {
+			return plain, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	key, err := newKey(password, h.Salt, h.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Check != nil && !hmac.Equal(h.Check, key.check) {
+		return nil, nil, ErrWrongPassword
+	}
+	plain, err := key.Open(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plain, key, nil
+}
+
+// Seal шифрует данные ключом сессии со свежим nonce
+func (k *Key) Seal(data []byte) ([]byte, error) {
+	if k.key == nil {
+		return nil, ErrKeyWiped
+	}
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	h := header{Version: headerVersion, Params: k.params, Salt: k.salt, Check: k.check, Nonce: nonce}
+	hdr, err := h.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(hdr, nonce, data, hdr), nil
+}
+
+// Open расшифровывает данные, записанные этим же ключом
+func (k *Key) Open(data []byte) ([]byte, error) {
+	if k.key == nil {
+		return nil, ErrKeyWiped
+	}
+	if !hasHeader(data) {
+		return nil, ErrKeyMismatch
+	}
+	h, hdr, ciphertext, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if h.Params != k.params || !bytes.Equal(h.Salt, k.salt) {
+		return nil, ErrKeyMismatch
+	}
+
+	gcm, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.Nonce) != gcm.NonceSize() {
+		return nil, ErrInvalidHeader
+	}
+
+	plain, err := gcm.Open(nil, h.Nonce, ciphertext, hdr)
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+	return plain, nil
+}
+
+// Verifier возвращает проверочное значение ключа без самого ключа
+func (k *Key) Verifier() *Verifier {
+	return &Verifier{params: k.params, salt: k.salt, check: k.check}
+}
+
+// Wipe затирает ключ в памяти; после этого Seal и Open возвращают ошибку
+func (k *Key) Wipe() {
+	for i := range k.key {
+		k.key[i] = 0
+	}
+	k.key = nil
+}
@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// testParams — быстрые параметры, чтобы тесты не ждали Argon2id
+var testParams = Params{KDF: KDFPBKDF2, Iterations: 1000, SaltLen: 16, Cipher: CipherAES256GCM}
+
+func sealTest(t *testing.T, password string, plain []byte) (*Key, []byte) {
+	t.Helper()
+	key, err := DeriveKey([]byte(password), testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := key.Seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, data
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	plain := []byte(`{"accounts":[]}`)
+	key, data := sealTest(t, "пароль", plain)
+
+	if !hasHeader(data) {
+		t.Fatalf("Seal записал данные без заголовка")
+	}
+	got, err := key.Open(data)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Key.Open = %q, %v", got, err)
+	}
+
+	got, opened, err := OpenWithPassword(data, []byte("пароль"))
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("OpenWithPassword = %q, %v", got, err)
+	}
+	// Ключ, восстановленный из заголовка, пишет данные, которые читает исходный
+	again, err := opened.Seal(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := key.Open(again); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Open после повторного Seal = %q, %v", got, err)
+	}
+}
+
+func TestOpenWithPasswordWrongPassword(t *testing.T) {
+	_, data := sealTest(t, "верный", []byte("данные"))
+	if _, _, err := OpenWithPassword(data, []byte("неверный")); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("неверный пароль: %v, ожидалась ErrWrongPassword", err)
+	}
+}
+
+func TestOpenWithPasswordInvalidHeader(t *testing.T) {
+	_, data := sealTest(t, "верный", []byte("данные"))
+	// Обрезанный заголовок — повреждение, а не неверный пароль
+	_, _, err := OpenWithPassword(data[:10], []byte("верный"))
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("обрезанный заголовок: %v, ожидалась ErrInvalidHeader", err)
+	}
+	if errors.Is(err, ErrWrongPassword) || errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("повреждённый заголовок принят за неверный пароль")
+	}
+
+	bad := bytes.Clone(data)
+	bad[len(magic)] = headerVersion + 1
+	if _, _, err := OpenWithPassword(bad, []byte("верный")); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("новая версия формата: %v, ожидалась ErrUnsupportedVersion", err)
+	}
+}
+
+func TestKeyOpenRejectsOtherKey(t *testing.T) {
+	key, _ := sealTest(t, "пароль", nil)
+
+	// Тот же пароль, но другая соль
+	_, otherSalt := sealTest(t, "пароль", []byte("данные"))
+	if _, err := key.Open(otherSalt); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("другая соль: %v, ожидалась ErrKeyMismatch", err)
+	}
+
+	// Те же соль и пароль, но другие параметры
+	params := testParams
+	params.Iterations++
+	other, err := newKey([]byte("пароль"), key.salt, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherParams, err := other.Seal([]byte("данные"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key.Open(otherParams); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("другие параметры: %v, ожидалась ErrKeyMismatch", err)
+	}
+
+	if _, err := key.Open([]byte("без заголовка")); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("данные без заголовка: %v, ожидалась ErrKeyMismatch", err)
+	}
+}
+
+func TestTamperedDataFailsGCM(t *testing.T) {
+	key, data := sealTest(t, "пароль", []byte("данные"))
+	_, hdr, _, err := parseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Последний байт заголовка — часть nonce; заголовок целиком входит
+	// в дополнительные данные GCM
+	tampered := bytes.Clone(data)
+	tampered[len(hdr)-1] ^= 1
+	if _, err := key.Open(tampered); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("изменённый заголовок: %v, ожидалась ErrAuthFailed", err)
+	}
+
+	tampered = bytes.Clone(data)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := key.Open(tampered); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("изменённый шифротекст: %v, ожидалась ErrAuthFailed", err)
+	}
+}
+
+func TestKeyWipe(t *testing.T) {
+	key, data := sealTest(t, "пароль", []byte("данные"))
+	key.Wipe()
+	if _, err := key.Open(data); !errors.Is(err, ErrKeyWiped) {
+		t.Errorf("Open после Wipe: %v", err)
+	}
+	if _, err := key.Seal(nil); !errors.Is(err, ErrKeyWiped) {
+		t.Errorf("Seal после Wipe: %v", err)
+	}
+}
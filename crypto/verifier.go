@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+var ErrNoVerifier = errors.New("в заголовке нет проверочного значения")
+
+const checkLabel = "passman master password check"
+
+// Verifier проверяет мастер-пароль по параметрам и проверочному значению
+// из заголовка, не имея доступа к ключу и данным
+type Verifier struct {
+	params Params
+	salt   []byte
+	check  []byte
+}
+
+// ReadVerifier извлекает проверочное значение из заголовка зашифрованных данных
+func ReadVerifier(data []byte) (*Verifier, error) {
+	if !hasHeader(data) {
+		return nil, ErrNoVerifier
+	}
+	h, _, _, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.Check) == 0 {
+		return nil, ErrNoVerifier
+	}
+	return &Verifier{params: h.Params, salt: h.Salt, check: h.Check}, nil
+}
+
+// Verify вырабатывает ключ из пароля и сравнивает его проверочное значение
+func (v *Verifier) Verify(password []byte) bool {
+	if v == nil {
+		return false
+	}
+	key, err := deriveKey(password, v.salt, v.params)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(checkValue(key), v.check)
+}
+
+// Unlock заново вырабатывает ключ из пароля с теми же солью и параметрами
+func (v *Verifier) Unlock(password []byte) (*Key, error) {
+	if v == nil {
+		return nil, ErrNoVerifier
+	}
+	key, err := newKey(password, v.salt, v.params)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(key.check, v.check) {
+		key.Wipe()
+		return nil, ErrWrongPassword
+	}
+	return key, nil
+}
+
+// checkValue — HMAC-SHA256 ключа от фиксированной метки, усечённый до 16 байт
+func checkValue(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(checkLabel))
+	return mac.Sum(nil)[:16]
+}
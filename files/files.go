@@ -1,9 +1,11 @@
 package files
 
 import (
+	"errors"
 	"fmt"
 	"menedger_paroley/output"
 	"os"
+	"path/filepath"
 )
 
 type JsonDb struct {
@@ -15,15 +17,26 @@ func NewJsonDb(name string) *JsonDb {
 }
 
 func (db *JsonDb) WriteFile(content []byte) error {
-	file, err := os.OpenFile(db.name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	return db.Write(content)
+}
+
+func (db *JsonDb) ReadFile() ([]byte, error) {
+	data, err := os.ReadFile(db.name)
 	if err != nil {
-		output.PrintError(err)
-		return err
+		return nil, err
 	}
-	defer file.Close()
+	return data, nil
+}
 
-	_, err = file.Write(content)
-	if err != nil {
+func (db *JsonDb) Read() ([]byte, error) {
+	return os.ReadFile(db.name)
+}
+
+// Write записывает данные атомарно: сначала во временный файл,
+// затем переименовывает его поверх старого, чтобы сбой не оставил
+// полузаписанный сейф
+func (db *JsonDb) Write(data []byte) error {
+	if err := writeAtomic(db.name, data); err != nil {
 		output.PrintError(err)
 		return err
 	}
@@ -32,33 +45,64 @@ func (db *JsonDb) WriteFile(content []byte) error {
 	return nil
 }
 
-func (db *JsonDb) ReadFile() ([]byte, error) {
-	data, err := os.ReadFile(db.name)
-	if err != nil {
-		return nil, err
+// Вложения хранятся отдельными файлами в каталоге <имя сейфа>.blobs
+
+func (db *JsonDb) blobPath(id string) (string, error) {
+	if id == "" || filepath.Base(id) != id {
+		return "", fmt.Errorf("некорректный ID вложения %q", id)
 	}
-	return data, nil
+	return filepath.Join(db.name+".blobs", id), nil
 }
 
-func (db *JsonDb) Read() ([]byte, error) {
-	return os.ReadFile(db.name)
+func (db *JsonDb) ReadBlob(id string) ([]byte, error) {
+	path, err := db.blobPath(id)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
 }
 
-func (db *JsonDb) Write(data []byte) error {
-	file, err := os.OpenFile(db.name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+func (db *JsonDb) WriteBlob(id string, data []byte) error {
+	path, err := db.blobPath(id)
 	if err != nil {
-		output.PrintError(err)
 		return err
 	}
-	defer file.Close()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeAtomic(path, data)
+}
 
-	_, err = file.Write(data)
+func (db *JsonDb) DeleteBlob(id string) error {
+	path, err := db.blobPath(id)
 	if err != nil {
-		output.PrintError(err)
 		return err
 	}
-	fmt.Println("Запись успешна")
-	os.Stdout.Sync()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
 	return nil
 }
 
+func writeAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package input
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+var ErrMismatch = errors.New("пароли не совпадают")
+
+// Один общий буфер на stdin: если каждая подсказка создаёт свой
+// bufio.Reader, при вводе через pipe первый же читатель забирает
+// все строки, и следующие подсказки получают пустоту
+var (
+	stdin     = bufio.NewReader(os.Stdin)
+	passwords *bufio.Reader
+	eof       bool
+	afterRead func()
+	mu        sync.Mutex
+)
+
+// SetAfterRead задаёт функцию, которая вызывается после каждого ввода до того,
+// как строка вернётся вызывающему, например чтобы потребовать мастер-пароль,
+// если сеанс заблокировался, пока программа ждала ввода. nil — отключить.
+func SetAfterRead(f func()) {
+	mu.Lock()
+	afterRead = f
+	mu.Unlock()
+}
+
+// Line печатает подсказку и читает строку из stdin
+func Line(prompt string) string {
+	fmt.Print(prompt)
+	mu.Lock()
+	text, hook := readLine(stdin), afterRead
+	mu.Unlock()
+	if hook != nil {
+		hook()
+	}
+	return text
+}
+
+// Password читает пароль. Пока в источнике паролей (UsePasswordFile)
+// есть строки, пароль берётся оттуда; если stdin — терминал, ввод
+// не отображается; иначе строка читается из stdin как есть.
+func Password(prompt string) string {
+	mu.Lock()
+	text, hook := readPassword(prompt), afterRead
+	mu.Unlock()
+	if hook != nil {
+		hook()
+	}
+	return text
+}
+
+func readPassword(prompt string) string {
+
+	if passwords != nil {
+		text, err := passwords.ReadString('\n')
+		if err == nil || text != "" {
+			return strings.TrimSpace(text)
+		}
+		// Источник исчерпан — дальше спрашиваем как обычно
+		passwords = nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		fmt.Print(prompt)
+		return readLine(stdin)
+	}
+
+	fmt.Print(prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		eof = true
+		return ""
+	}
+	return strings.TrimSpace(string(password))
+}
+
+// NewPassword просит ввести новый пароль дважды
+func NewPassword(prompt string) (string, error) {
+	password := Password(prompt)
+	if password != Password("Повторите пароль: ") {
+		return "", ErrMismatch
+	}
+	return password, nil
+}
+
+// UsePasswordFile задаёт источник паролей для сценариев, например
+// файловый дескриптор из флага -password-fd. Пароли читаются по строке.
+func UsePasswordFile(f *os.File) {
+	mu.Lock()
+	passwords = bufio.NewReader(f)
+	mu.Unlock()
+}
+
+// EOF сообщает, что ввод закончился, и ждать новых строк бессмысленно
+func EOF() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return eof
+}
+
+func readLine(r *bufio.Reader) string {
+	text, err := r.ReadString('\n')
+	if err == io.EOF && text == "" {
+		eof = true
+	}
+	return strings.TrimSpace(text)
+}
@@ -1,13 +1,18 @@
 package app
 
 import (
-	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"math/rand/v2"
 	"menedger_paroley/account"
+	"menedger_paroley/cloud"
 	"menedger_paroley/crypto"
 	"menedger_paroley/files"
+	"menedger_paroley/input"
+	"menedger_paroley/internal/auth"
+	"menedger_paroley/otp"
 	"menedger_paroley/output"
 	"os"
 	"strings"
@@ -23,18 +28,42 @@ var (
 	Mu         sync.Mutex
 )
 
-func RunCLI(vault *account.VaultWithDb, password string) {
+// shownResults — сколько лучших результатов поиска выводится на экран
+const shownResults = 50
+
+// RunCLI запускает меню. Если idle > 0, после такого бездействия сеанс
+// блокируется и любое действие, кроме выхода, требует мастер-пароль.
+func RunCLI(vault *account.VaultWithDb, guard *auth.Guard, idle time.Duration) {
+	lock := newIdleLock(vault, guard, idle)
+	defer lock.stop()
+	defer input.SetAfterRead(nil)
+
+	if report := vault.ExpiryReport(time.Now()); len(report) > 0 {
+		color.Yellow("Паролей, которые пора сменить: %d — см. пункт 15", len(report))
+	}
+
 	for {
 		showMenu()
-		choice := prompt("Выберите: ")
+		choice := input.Line("Выберите: ")
+		if input.EOF() {
+			return
+		}
+
+		if !vault.HasKey() && choice != "4" {
+			unlockSession(vault, guard)
+			lock.touch()
+			continue
+		}
+		lock.touch()
+		input.SetAfterRead(lock.afterInput)
 
 		switch choice {
 		case "1":
-			createAccount(vault, password)
+			createAccount(vault)
 		case "2":
 			findAccount(vault)
 		case "3":
-			deleteAccount(vault, password)
+			deleteAccount(vault)
 		case "4":
 			color.Green("Выход...")
 			return
@@ -46,9 +75,25 @@ func RunCLI(vault *account.VaultWithDb, password string) {
 			backupVault(vault)
 		case "8":
 			restoreFromBackup(vault)
+		case "9":
+			changeMasterPassword(vault, guard)
+		case "10":
+			editAccount(vault)
+		case "11":
+			trashMenu(vault)
+		case "12":
+			passwordHistory(vault)
+		case "13":
+			organizeMenu(vault)
+		case "14":
+			attachmentsMenu(vault)
+		case "15":
+			expiryMenu(vault)
 		default:
 			output.PrintError("Неверный выбор")
 		}
+		input.SetAfterRead(nil)
+		lock.touch()
 	}
 }
 
@@ -59,52 +104,91 @@ func showMenu() {
 	color.White("3. Удалить аккаунт")
 	color.White("4. Выход")
 	color.White("5. Сгенерировать пароль")
-	color.White("6. Скопировать пароль")
+	color.White("6. Скопировать пароль или код 2FA")
 	color.White("7. Создать резервную копию")
 	color.White("8. Восстановить из бэкапа")
+	color.White("9. Сменить мастер-пароль")
+	color.White("10. Изменить аккаунт")
+	color.White("11. Корзина")
+	color.White("12. История паролей")
+	color.White("13. Папки и теги")
+	color.White("14. Вложения")
+	color.White("15. Сроки смены паролей")
 }
 
-func prompt(prompt string) string {
-	print(prompt)
-	scanner := bufio.NewReader(os.Stdin)
-	text, _ := scanner.ReadString('\n')
-	return strings.TrimSpace(text)
-}
-
-func PromptPassword(prompt string) string {
-	fmt.Print(prompt)
-	scanner := bufio.NewReader(os.Stdin)
-	password, _ := scanner.ReadString('\n')
-	return strings.TrimSpace(password)
-}
-
-func createAccount(vault *account.VaultWithDb, password string) {
-	name := prompt("Имя: ")
-	login := prompt("Логин: ")
-	pass := prompt("Пароль (Enter — сгенерировать): ")
-	url := prompt("URL: ")
-
-	if pass == "" {
-		pass = generateRandomPassword(12)
+func createAccount(vault *account.VaultWithDb) {
+	kind, ok := promptItemType()
+	if !ok {
+		output.PrintError("Неверный выбор")
+		return
+	}
+	name := input.Line("Имя: ")
+
+	var acc *account.Account
+	var err error
+	if kind == account.TypeLogin {
+		login := input.Line("Логин: ")
+		pass := input.Password("Пароль (Enter — сгенерировать): ")
+		var uris []account.URI
+		uris, err = account.ParseURIs(input.Line("URL (несколько — через запятую, правило после пробела: domain, host, prefix, exact, regex, never): "))
+		if err == nil {
+			if pass == "" {
+				pass = generateRandomPassword(12)
+			}
+			acc, err = account.NewLogin(name, login, pass, uris)
+		}
+	} else {
+		acc, err = newItem(kind, name)
 	}
-
-	acc, err := account.NewAccount(name, login, pass, url)
 	if err != nil {
 		output.PrintError(err)
 		return
 	}
 
-	vault.AddAccount(*acc)
-	err = SaveEncrypted(vault, password)
+	folder := input.Line("Папка (Enter — без папки): ")
+	tags := account.ParseTags(input.Line("Теги через запятую: "))
+	if kind != account.TypeNote {
+		acc.Notes = input.Line("Заметки (Enter — пропустить): ")
+	}
+	otpKey, err := promptOTP("2FA: otpauth:// или base32 (Enter — пропустить): ")
 	if err != nil {
-		output.PrintError("Ошибка сохранения")
+		output.PrintError(err)
+		return
 	}
-	color.Green("Аккаунт добавлен")
+	acc.Folder = account.NormalizeFolder(folder)
+	acc.Tags = tags
+	acc.OTP = otpKey
+	if input.Line("Добавить поля (вопросы, PIN, ключи)? [д/Enter]: ") == "д" {
+		fields, _ := editFields(nil)
+		if err := acc.Apply(account.AccountUpdate{Fields: &fields}); err != nil {
+			output.PrintError(err)
+			return
+		}
+	}
+
+	vault.AddAccount(*acc)
+	saveVault(vault, fmt.Sprintf("Запись добавлена: %s", acc.Kind()))
 }
 
 func findAccount(vault *account.VaultWithDb) {
-	query := prompt("Поиск: ")
-	accounts := vault.FindAccount(query) // ← не Data.FindAccount
+	color.Cyan("Можно: name: login: url: tag: folder: type: has:otp, OR, NOT (или -), кавычки и скобки")
+	query := input.Line("Поиск (или адрес страницы https://…): ")
+	var accounts []account.Account
+	total := 0
+	if account.IsPageURL(query) {
+		accounts = vault.FindByURL(query)
+		total = len(accounts)
+	} else {
+		results, n, err := vault.QueryTop(query, shownResults)
+		if err != nil {
+			output.PrintError(err)
+			return
+		}
+		for _, r := range results {
+			accounts = append(accounts, r.Account)
+		}
+		total = n
+	}
 	if len(accounts) == 0 {
 		output.PrintError("Не найдено")
 		return
@@ -112,24 +196,236 @@ func findAccount(vault *account.VaultWithDb) {
 	for _, acc := range accounts {
 		acc.Output()
 	}
+	printShown(len(accounts), total)
+	// Просмотром считаем только однозначный результат. Сейф ради этого
+	// не перезаписывается: счётчик сохранится вместе со следующим изменением
+	if total == 1 {
+		vault.MarkUsed(accounts[0].ID)
+	}
+}
+
+// printShown сообщает, что на экран попали не все найденные записи
+func printShown(shown, total int) {
+	if shown < total {
+		color.Yellow("Показаны %d из %d — уточните запрос", shown, total)
+	}
+}
+
+// markUsed отмечает копирование из записи и сохраняет сейф, чтобы частые
+// записи поднимались в результатах поиска
+func markUsed(vault *account.VaultWithDb, id string) {
+	if err := vault.MarkUsed(id); err != nil {
+		return
+	}
+	if err := vault.Save(); err != nil {
+		output.PrintError("Ошибка сохранения")
+	}
+}
+
+// deleteAccount показывает найденные по URL или по запросу записи, даёт
+// выбрать одну или несколько и удаляет их только после подтверждения.
+// Заметки, карты и другие записи без адреса находятся только запросом.
+func deleteAccount(vault *account.VaultWithDb) {
+	color.White("Режим поиска: 1. %s  2. %s  3. %s  4. по имени или запросу", account.MatchExact, account.MatchHost, account.MatchSubstring)
+	var accounts []account.Account
+	switch input.Line("Режим [2]: ") {
+	case "1":
+		accounts = vault.SearchByURL(input.Line("URL для удаления: "), account.MatchExact)
+	case "3":
+		accounts = vault.SearchByURL(input.Line("URL для удаления: "), account.MatchSubstring)
+	case "4":
+		accounts = vault.FindAccount(input.Line("Поиск: "))
+	default:
+		accounts = vault.SearchByURL(input.Line("URL для удаления: "), account.MatchHost)
+	}
+	if len(accounts) == 0 {
+		output.PrintError("Не найдено")
+		return
+	}
+
+	for i, a := range accounts {
+		color.White("%d. %s (%s) %s [%s]", i+1, a.Name, a.Summary(), a.PrimaryURL(), shortID(a.ID))
+	}
+	picked, err := parseSelection(input.Line("Номера через запятую, * — все, Enter — отмена: "), len(accounts))
+	if err != nil {
+		output.PrintError(err)
+		return
+	}
+	if len(picked) == 0 {
+		color.Yellow("Отменено")
+		return
+	}
+
+	color.Yellow("Будут удалены:")
+	for _, i := range picked {
+		color.Yellow("  %s (%s) %s", accounts[i].Name, accounts[i].Summary(), accounts[i].PrimaryURL())
+	}
+	if input.Line(fmt.Sprintf("Удалить записей: %d? Введите «да»: ", len(picked))) != "да" {
+		color.Yellow("Отменено")
+		return
+	}
+
+	deleted := 0
+	for _, i := range picked {
+		if vault.Delete(accounts[i].ID) {
+			deleted++
+		}
+	}
+	saveVault(vault, fmt.Sprintf("Перемещено в корзину: %d", deleted))
 }
 
-func deleteAccount(vault *account.VaultWithDb, password string) {
-	url := prompt("Частичный URL для удаления: ")
-	if vault.DeleteAccountByURL(url) {
-		err := SaveEncrypted(vault, password)
+// parseSelection разбирает выбор вида "1,3-5" или "*" в индексы от нуля
+func parseSelection(choice string, n int) ([]int, error) {
+	choice = strings.TrimSpace(choice)
+	if choice == "" {
+		return nil, nil
+	}
+	if choice == "*" {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+
+	seen := make(map[int]bool)
+	var picked []int
+	for _, part := range strings.Split(choice, ",") {
+		var from, to int
+		part = strings.TrimSpace(part)
+		if _, err := fmt.Sscanf(part, "%d-%d", &from, &to); err != nil {
+			if _, err := fmt.Sscanf(part, "%d", &from); err != nil {
+				return nil, fmt.Errorf("неверный номер: %q", part)
+			}
+			to = from
+		}
+		if from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("неверный номер: %q", part)
+		}
+		for i := from; i <= to; i++ {
+			if !seen[i] {
+				seen[i] = true
+				picked = append(picked, i-1)
+			}
+		}
+	}
+	return picked, nil
+}
+
+func editAccount(vault *account.VaultWithDb) {
+	acc, ok := selectAccount(vault)
+	if !ok {
+		return
+	}
+
+	color.Cyan("Enter — оставить как есть")
+	var upd account.AccountUpdate
+	upd.Name = promptChange("Имя", acc.Name)
+	if acc.Kind() == account.TypeLogin {
+		upd.Login = promptChange("Логин", acc.Login)
+		if uris := promptChange("Адреса", account.FormatURIs(acc.URIs)); uris != nil {
+			parsed, err := account.ParseURIs(*uris)
+			if err != nil {
+				output.PrintError(err)
+				return
+			}
+			upd.URIs = &parsed
+		}
+	} else if !editItemData(acc, &upd) {
+		return
+	}
+	upd.Folder = promptChange("Папка", acc.Folder)
+	if tags := promptChange("Теги", strings.Join(acc.Tags, ", ")); tags != nil {
+		parsed := account.ParseTags(*tags)
+		upd.Tags = &parsed
+	}
+	if acc.Kind() != account.TypeNote {
+		upd.Notes = promptChange("Заметки", acc.Notes)
+	}
+	if acc.Kind() == account.TypeLogin {
+		switch pass := input.Password("Новый пароль (* — сгенерировать): "); pass {
+		case "":
+		case "*":
+			pass = generateRandomPassword(12)
+			upd.Password = &pass
+		default:
+			upd.Password = &pass
+		}
+	}
+
+	status := "нет"
+	if acc.OTP != nil {
+		status = "настроен"
+	}
+	switch value := input.Password(fmt.Sprintf("2FA [%s]: otpauth:// или base32, - — убрать: ", status)); value {
+	case "":
+	case "-":
+		upd.ClearOTP = acc.OTP != nil
+	default:
+		key, err := otp.Parse(value)
 		if err != nil {
-			output.PrintError("Ошибка сохранения")
+			output.PrintError(err)
+			return
+		}
+		upd.OTP = key
+	}
+
+	favorite := "нет"
+	if acc.Favorite {
+		favorite = "да"
+	}
+	switch input.Line(fmt.Sprintf("Избранное [%s] (да/нет): ", favorite)) {
+	case "да", "д":
+		if !acc.Favorite {
+			yes := true
+			upd.Favorite = &yes
+		}
+	case "нет", "н":
+		if acc.Favorite {
+			no := false
+			upd.Favorite = &no
+		}
+	}
+
+	if input.Line("Изменить поля? [д/Enter]: ") == "д" {
+		if fields, changed := editFields(acc.Fields); changed {
+			upd.Fields = &fields
 		}
-		color.Green("Удалено")
-	} else {
-		output.PrintError("Не найдено")
 	}
+
+	if upd.IsEmpty() {
+		color.Yellow("Без изменений")
+		return
+	}
+	updated, err := vault.Update(acc.ID, upd)
+	if err != nil {
+		output.PrintError(err)
+		return
+	}
+	saveVault(vault, fmt.Sprintf("Запись «%s» обновлена", updated.Name))
+}
+
+// saveVault сохраняет сейф и при успехе показывает msg
+func saveVault(vault *account.VaultWithDb, msg string) {
+	if err := vault.Save(); err != nil {
+		output.PrintError("Ошибка сохранения")
+		return
+	}
+	color.Green(msg)
+}
+
+// promptChange показывает текущее значение и возвращает новое или nil, если поле не меняется
+func promptChange(label, current string) *string {
+	value := input.Line(fmt.Sprintf("%s [%s]: ", label, current))
+	if value == "" || value == current {
+		return nil
+	}
+	return &value
 }
 
 func generatePassword() {
 	n := 12
-	length := prompt("Длина (8–128): ")
+	length := input.Line("Длина (8–128): ")
 	if _, err := fmt.Sscanf(length, "%d", &n); err != nil || n < 8 || n > 128 {
 		n = 12
 	}
@@ -146,33 +442,124 @@ func generateRandomPassword(n int) string {
 	return string(res)
 }
 
-func copyPassword(vault *account.VaultWithDb) {
-	query := prompt("Поиск: ")
-	accounts := vault.FindAccount(query)
+// selectAccount ищет аккаунты и, если найдено несколько, просит выбрать
+// один по номеру в списке или по началу ID; в списке — не больше
+// shownResults лучших. Единственную запись, которая
+// нашлась только с опечаткой, нужно подтвердить: похожее слово могло
+// найти не ту запись. Запись перечитывается по ID, чтобы действие
+// касалось ровно её.
+func selectAccount(vault *account.VaultWithDb) (account.Account, bool) {
+	query := input.Line("Поиск: ")
+	accounts, total := vault.FindBest(query, shownResults)
 	if len(accounts) == 0 {
 		output.PrintError("Не найдено")
-		return
+		return account.Account{}, false
 	}
 
-	var acc account.Account
-	if len(accounts) == 1 {
-		acc = accounts[0]
+	id := accounts[0].ID
+	if total == 1 {
+		a := accounts[0]
+		color.White("Запись: %s (%s) [%s]", a.Name, a.Summary(), shortID(a.ID))
+		if !a.MatchesExactly(query) && input.Line("Найдено по похожему слову. Это она? [д/Enter]: ") != "д" {
+			color.Yellow("Отменено")
+			return account.Account{}, false
+		}
 	} else {
 		for i, a := range accounts {
-			color.White("%d. %s (%s)", i+1, a.Name, a.Login)
+			color.White("%d. %s (%s) [%s]", i+1, a.Name, a.Summary(), shortID(a.ID))
 		}
-		idx := prompt("Выберите номер: ")
-		var n int
-		fmt.Sscanf(idx, "%d", &n)
-		if n < 1 || n > len(accounts) {
+		printShown(len(accounts), total)
+		id = pickID(accounts, input.Line("Выберите номер или ID: "))
+		if id == "" {
 			output.PrintError("Неверный номер")
+			return account.Account{}, false
+		}
+	}
+
+	acc, ok := vault.Get(id)
+	if !ok {
+		output.PrintError("Не найдено")
+	}
+	return acc, ok
+}
+
+// pickID принимает номер в списке или однозначное начало ID
+func pickID(accounts []account.Account, choice string) string {
+	var n int
+	if _, err := fmt.Sscanf(choice, "%d", &n); err == nil && fmt.Sprint(n) == choice {
+		if n >= 1 && n <= len(accounts) {
+			return accounts[n-1].ID
+		}
+		return ""
+	}
+
+	id := ""
+	for _, a := range accounts {
+		if choice != "" && strings.HasPrefix(a.ID, choice) {
+			if id != "" {
+				return ""
+			}
+			id = a.ID
+		}
+	}
+	return id
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func copyPassword(vault *account.VaultWithDb) {
+	acc, ok := selectAccount(vault)
+	if !ok {
+		return
+	}
+
+	if acc.OTP != nil && input.Line("1. Секрет  2. Код 2FA [1]: ") == "2" {
+		if acc.OTP.CounterBased() {
+			code, err := vault.NextOTPCode(acc.ID)
+			if err != nil {
+				output.PrintError(err)
+				return
+			}
+			copyToClipboard(code, fmt.Sprintf("Код HOTP %s для «%s» скопирован", code, acc.Name))
+			markUsed(vault, acc.ID)
+			return
+		}
+		code, left, err := acc.OTP.Code(time.Now())
+		if err != nil {
+			output.PrintError(err)
 			return
 		}
-		acc = accounts[n-1]
+		copyToClipboard(code, fmt.Sprintf("Код 2FA %s для «%s» скопирован, действует ещё %d с", code, acc.Name, int(left.Seconds())))
+		markUsed(vault, acc.ID)
+		return
+	}
+	label, secret := acc.Secret()
+	if secret == "" {
+		output.PrintError(label + ": пусто")
+		return
+	}
+	copyToClipboard(secret, fmt.Sprintf("Скопировано из «%s»: %s", acc.Name, label))
+	markUsed(vault, acc.ID)
+}
+
+// promptOTP читает ссылку otpauth:// или секрет base32; пустой ввод — без 2FA
+func promptOTP(msg string) (*otp.Key, error) {
+	value := input.Password(msg)
+	if value == "" {
+		return nil, nil
 	}
+	return otp.Parse(value)
+}
 
-	clipboard.WriteAll(acc.Password)
-	color.Green("Пароль скопирован")
+// copyToClipboard копирует значение и очищает буфер через 10 секунд
+func copyToClipboard(value, msg string) {
+	clipboard.WriteAll(value)
+	color.Green(msg)
 
 	if clearTimer != nil {
 		clearTimer.Stop()
@@ -183,14 +570,40 @@ func copyPassword(vault *account.VaultWithDb) {
 	})
 }
 
+// passwordHistory показывает прежние пароли аккаунта и копирует выбранный
+func passwordHistory(vault *account.VaultWithDb) {
+	acc, ok := selectAccount(vault)
+	if !ok {
+		return
+	}
+	if len(acc.PasswordHistory) == 0 {
+		color.Yellow("Пароль не менялся")
+		return
+	}
+
+	for i, rec := range acc.PasswordHistory {
+		color.White("%d. %s — заменён %s", i+1, account.MaskPassword(rec.Password), rec.ReplacedAt.Format("02.01.2006 15:04"))
+	}
+	choice := input.Line("Номер для копирования (Enter — назад): ")
+	if choice == "" {
+		return
+	}
+	var n int
+	if _, err := fmt.Sscanf(choice, "%d", &n); err != nil || n < 1 || n > len(acc.PasswordHistory) {
+		output.PrintError("Неверный номер")
+		return
+	}
+	copyToClipboard(acc.PasswordHistory[n-1].Password, fmt.Sprintf("Прежний пароль «%s» скопирован", acc.Name))
+}
+
 func backupVault(vault *account.VaultWithDb) {
-	data, err := json.MarshalIndent(&vault.Data, "", "  ")
+	data, err := vault.Export()
 	if err != nil {
-		output.PrintError("Ошибка экспорта")
+		output.PrintError(fmt.Errorf("Ошибка экспорта: %w", err))
 		return
 	}
 
-	password := PromptPassword("Пароль для бэкапа: ")
+	password := input.Password("Пароль для бэкапа: ")
 	if !isStrongPassword(password) {
 		color.Red("Слабый пароль. Используйте 8+ символов, цифры и спецсимволы.")
 		return
@@ -210,8 +623,8 @@ func backupVault(vault *account.VaultWithDb) {
 }
 
 func restoreFromBackup(vault *account.VaultWithDb) {
-	fn := prompt("Путь к бэкапу: ")
-	password := PromptPassword("Мастер-пароль: ")
+	fn := input.Line("Путь к бэкапу: ")
+	password := input.Password("Мастер-пароль: ")
 
 	data, err := files.NewJsonDb(fn).ReadFile()
 	if err != nil {
@@ -225,17 +638,21 @@ func restoreFromBackup(vault *account.VaultWithDb) {
 		return
 	}
 
+	// Сейф заменяется целиком, а вложения прежних записей удаляются при
+	// сохранении, поэтому файл, который не разобрался как сейф, не принимаем
 	var backup account.Vault
-	json.Unmarshal(decrypted, &backup)
+	if err := json.Unmarshal(decrypted, &backup); err != nil {
+		output.PrintError("Файл не является резервной копией сейфа")
+		return
+	}
+	backup.Migrate()
 
-	vault.Lock()
-	vault.Data.Accounts = backup.Accounts
-	vault.Data.UpdatedAt = time.Now()
-	vault.Data.Verification = "VERIFIED"
-	vault.Unlock()
+	if err := vault.ReplaceAccounts(backup.Accounts); err != nil {
+		output.PrintError(err)
+		return
+	}
 
-	vault.Save()
-	color.Green("Восстановлено!")
+	saveVault(vault, "Восстановлено!")
 }
 
 func isStrongPassword(p string) bool {
@@ -259,51 +676,105 @@ func isStrongPassword(p string) bool {
 	return upper && lower && digit && special
 }
 
-func LoadVault(db account.Db, password string) *account.VaultWithDb {
+// ErrVaultNotFound — сейфа в хранилище нет, а создавать новый нельзя
+var ErrVaultNotFound = errors.New("сейф не найден")
+
+// LoadVault читает сейф и один раз вырабатывает ключ сессии из мастер-пароля.
+// Успешная расшифровка и есть проверка пароля: проверочное значение
+// хранится в заголовке сейфа, поэтому войти можно с любой машины,
+// которая видит хранилище. Неудачные попытки считает guard.
+// Если сейфа ещё нет, он создаётся.
+func LoadVault(db account.Db, guard *auth.Guard, password string) (*account.VaultWithDb, error) {
+	return loadVault(db, guard, password, true)
+}
+
+// LoadExistingVault работает как LoadVault, но вместо создания нового
+// сейфа возвращает ErrVaultNotFound
+func LoadExistingVault(db account.Db, guard *auth.Guard, password string) (*account.VaultWithDb, error) {
+	return loadVault(db, guard, password, false)
+}
+
+func loadVault(db account.Db, guard *auth.Guard, password string, create bool) (*account.VaultWithDb, error) {
+	if err := guard.Check(); err != nil {
+		return nil, err
+	}
+
+	// Новый сейф создаётся, только если файла действительно нет:
+	// сетевая ошибка или отказ сервера не должны привести к перезаписи
 	data, err := db.Read()
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, cloud.ErrNotFound) {
+		return nil, fmt.Errorf("не удалось прочитать сейф: %w", err)
+	}
+	if (err != nil || len(data) == 0) && !create {
+		return nil, ErrVaultNotFound
+	}
+	if err != nil || len(data) == 0 {
 		color.Cyan("Файл не найден. Создаём новый сейф.")
-		return &account.VaultWithDb{
-			Data: account.Vault{
-				Accounts:  []account.Account{},
-				UpdatedAt: time.Now(),
-			},
-			Db: db,
+		if input.Password("Повторите мастер-пароль: ") != password {
+			return nil, input.ErrMismatch
+		}
+		vault, err := account.NewVault(db, password)
+		if err != nil {
+			return nil, err
 		}
+		if err := vault.Save(); err != nil {
+			return nil, err
+		}
+		auth.RemoveLegacyToken()
+		color.Green("Мастер-пароль установлен")
+		return vault, nil
 	}
 
-	// Сначала попробуем расшифровать
-	decrypted, err := crypto.Decrypt(data, []byte(password))
-	if err != nil {
-		// Если не получилось — может, файл не шифровался?
-		var vault account.Vault
-		if json.Unmarshal(data, &vault) == nil {
-			color.Yellow("Загружено без шифрования")
-			return &account.VaultWithDb{Data: vault, Db: db}
+	// Может, файл не шифровался?
+	var plain account.Vault
+	if json.Unmarshal(data, &plain) == nil {
+		color.Yellow("Загружено без шифрования")
+		vault, err := account.NewVault(db, password)
+		if err != nil {
+			return nil, err
 		}
-		color.Red("Неверный пароль или повреждённый файл")
-		os.Exit(1)
+		plain.Migrate()
+		vault.Data = plain
+		return vault, nil
 	}
 
-	var vault account.Vault
-	if json.Unmarshal(decrypted, &vault) != nil {
-		color.Red("Ошибка анализа данных")
-		os.Exit(1)
+	var vault *account.VaultWithDb
+	var openErr error
+	opens := auth.VerifierFunc(func(password []byte) bool {
+		vault, openErr = account.OpenVault(db, data, string(password))
+		// Повреждённый заголовок или новый формат — не повод считать попытку
+		return !errors.Is(openErr, account.ErrWrongPassword)
+	})
+	if err := guard.Verify(opens, password); err != nil {
+		return nil, err
+	}
+	if openErr != nil {
+		return nil, openErr
 	}
 
-	return &account.VaultWithDb{Data: vault, Db: db}
+	purged := vault.PurgeExpired(time.Now())
+	if vault.NeedsSave() || purged > 0 {
+		if err := vault.Save(); err != nil {
+			output.PrintError("Ошибка сохранения: " + err.Error())
+		}
+	}
+	migrateLegacyToken(vault)
+	return vault, nil
 }
 
-func SaveEncrypted(vault *account.VaultWithDb, password string) error {
-	data, err := vault.ToBytes()
-	if err != nil {
-		return err
+// migrateLegacyToken пересохраняет сейф с проверочным значением в заголовке
+// и удаляет token.enc прежних версий
+func migrateLegacyToken(vault *account.VaultWithDb) {
+	if !auth.HasLegacyToken() {
+		return
 	}
-
-	encrypted, err := crypto.Encrypt(data, []byte(password))
-	if err != nil {
-		return err
+	if err := vault.Save(); err != nil {
+		output.PrintError("Не удалось перенести проверку пароля в сейф: " + err.Error())
+		return
 	}
-
-	return vault.Db.Write(encrypted)
+	if err := auth.RemoveLegacyToken(); err != nil {
+		output.PrintError(err)
+		return
+	}
+	color.Green("Проверка мастер-пароля перенесена в сейф")
 }
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"menedger_paroley/account"
+	"menedger_paroley/input"
+	"menedger_paroley/output"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+)
+
+// attachmentsMenu показывает вложения записи и даёт добавить, сохранить
+// на диск или удалить их
+func attachmentsMenu(vault *account.VaultWithDb) {
+	acc, ok := selectAccount(vault)
+	if !ok {
+		return
+	}
+
+	if len(acc.Attachments) == 0 {
+		color.Yellow("Вложений нет")
+	}
+	for i, att := range acc.Attachments {
+		color.White("%d. %s (%s), добавлено %s", i+1, att.Name, account.FormatSize(att.Size), att.AddedAt.Format("02.01.2006"))
+	}
+
+	color.Cyan("1. Добавить  2. Сохранить на диск  3. Удалить  Enter — назад")
+	switch input.Line("Выберите: ") {
+	case "1":
+		path := input.Line("Путь к файлу: ")
+		info, err := os.Stat(path)
+		if err != nil {
+			output.PrintError("Файл не найден")
+			return
+		}
+		if info.Size() > account.MaxAttachmentSize {
+			output.PrintError(account.ErrAttachmentTooLarge)
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			output.PrintError(err)
+			return
+		}
+		att, err := vault.AddAttachment(acc.ID, path, data)
+		if err != nil {
+			output.PrintError(err)
+			return
+		}
+		saveVault(vault, fmt.Sprintf("Вложение %s добавлено к «%s»", att.Name, acc.Name))
+	case "2":
+		att, ok := pickAttachment(acc.Attachments)
+		if !ok {
+			return
+		}
+		target := input.Line(fmt.Sprintf("Куда сохранить [%s]: ", att.Name))
+		if target == "" {
+			target = att.Name
+		}
+		if info, err := os.Stat(target); err == nil && info.IsDir() {
+			target = filepath.Join(target, att.Name)
+		}
+		data, err := vault.ReadAttachment(acc.ID, att.ID)
+		if err != nil {
+			output.PrintError(err)
+			return
+		}
+		// O_EXCL: не перезаписываем существующие файлы молча
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if errors.Is(err, os.ErrExist) {
+			output.PrintError("Файл уже существует: " + target)
+			return
+		}
+		if err != nil {
+			output.PrintError(err)
+			return
+		}
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(target)
+			output.PrintError(err)
+			return
+		}
+		color.Green("Сохранено: %s", target)
+	case "3":
+		att, ok := pickAttachment(acc.Attachments)
+		if !ok {
+			return
+		}
+		if input.Line(fmt.Sprintf("Удалить вложение %s из «%s»? Введите «да»: ", att.Name, acc.Name)) != "да" {
+			color.Yellow("Отменено")
+			return
+		}
+		if err := vault.RemoveAttachment(acc.ID, att.ID); err != nil {
+			output.PrintError(err)
+			return
+		}
+		saveVault(vault, fmt.Sprintf("Вложение %s удалено из «%s»", att.Name, acc.Name))
+	}
+}
+
+func pickAttachment(atts []account.Attachment) (account.Attachment, bool) {
+	if len(atts) == 0 {
+		return account.Attachment{}, false
+	}
+	var n int
+	if _, err := fmt.Sscanf(input.Line("Номер вложения: "), "%d", &n); err != nil || n < 1 || n > len(atts) {
+		output.PrintError("Неверный номер")
+		return account.Attachment{}, false
+	}
+	return atts[n-1], true
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"fmt"
+	"menedger_paroley/account"
+	"menedger_paroley/input"
+	"menedger_paroley/output"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// expiryMenu показывает просроченные и скоро истекающие записи и даёт
+// настроить сроки смены паролей
+func expiryMenu(vault *account.VaultWithDb) {
+	settings := vault.Data.Settings
+	if settings.PasswordMaxAgeDays > 0 {
+		color.Cyan("Политика сейфа: менять пароли каждые %d дн., предупреждать за %d дн.",
+			settings.PasswordMaxAgeDays, int(settings.ExpiryWarning().Hours()/24))
+	} else {
+		color.Cyan("Политика сейфа: без ограничения возраста паролей")
+	}
+	printExpiryReport(vault.ExpiryReport(time.Now()))
+
+	color.Cyan("1. Политика сейфа  2. Срок для записи  Enter — назад")
+	switch input.Line("Выберите: ") {
+	case "1":
+		maxAge, ok := promptDays("Менять пароли каждые N дней (0 — без ограничения): ")
+		if !ok {
+			return
+		}
+		warn, ok := promptDays(fmt.Sprintf("Предупреждать за N дней (Enter — %d): ", account.DefaultExpiryWarningDays))
+		if !ok {
+			return
+		}
+		vault.SetPasswordPolicy(maxAge, warn)
+		saveVault(vault, "Политика сохранена")
+	case "2":
+		acc, ok := selectAccount(vault)
+		if !ok {
+			return
+		}
+		var upd account.AccountUpdate
+		switch value := input.Line("Сменить до ДД.ММ.ГГГГ (- — убрать срок, Enter — оставить): "); value {
+		case "":
+		case "-":
+			upd.ClearExpiresAt = acc.ExpiresAt != nil
+		default:
+			at, err := time.ParseInLocation("02.01.2006", value, time.Local)
+			if err != nil {
+				output.PrintError("Дата — в формате ДД.ММ.ГГГГ")
+				return
+			}
+			upd.ExpiresAt = &at
+		}
+		switch value := input.Line("Менять каждые N дней (0 — как в сейфе, - — никогда, Enter — оставить): "); value {
+		case "":
+		case "-":
+			days := account.NoMaxAge
+			upd.MaxAgeDays = &days
+		default:
+			var days int
+			if _, err := fmt.Sscanf(value, "%d", &days); err != nil || days < 0 {
+				output.PrintError("Введите число дней")
+				return
+			}
+			upd.MaxAgeDays = &days
+		}
+		if upd.IsEmpty() {
+			color.Yellow("Без изменений")
+			return
+		}
+		if _, err := vault.Update(acc.ID, upd); err != nil {
+			output.PrintError(err)
+			return
+		}
+		saveVault(vault, fmt.Sprintf("Срок для «%s» сохранён", acc.Name))
+	}
+}
+
+func printExpiryReport(report []account.ExpiryEntry) {
+	if len(report) == 0 {
+		color.Green("Просроченных и истекающих паролей нет")
+		return
+	}
+	now := time.Now()
+	for _, e := range report {
+		line := fmt.Sprintf("%s (%s) — срок %s", e.Account.Name, e.Account.Summary(), e.DueAt.Format("02.01.2006"))
+		if e.Expired {
+			color.Red("%s, просрочено на %d дн.", line, int(now.Sub(e.DueAt).Hours()/24))
+		} else {
+			color.Yellow("%s, осталось %d дн.", line, int(e.DueAt.Sub(now).Hours()/24)+1)
+		}
+	}
+}
+
+// promptDays читает неотрицательное число дней; пустой ввод — 0
+func promptDays(msg string) (int, bool) {
+	value := input.Line(msg)
+	if value == "" {
+		return 0, true
+	}
+	var days int
+	if _, err := fmt.Sscanf(value, "%d", &days); err != nil || days < 0 {
+		output.PrintError("Введите число дней")
+		return 0, false
+	}
+	return days, true
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"fmt"
+	"menedger_paroley/account"
+	"menedger_paroley/input"
+	"menedger_paroley/output"
+
+	"github.com/fatih/color"
+)
+
+// editFields показывает поля аккаунта и даёт добавить, изменить или удалить их.
+// Возвращает новый список и признак того, что он менялся.
+func editFields(fields []account.CustomField) ([]account.CustomField, bool) {
+	fields = append([]account.CustomField(nil), fields...)
+	changed := false
+	for {
+		for i, f := range fields {
+			color.White("%d. %s (%s): %s", i+1, f.Name, f.Type, f.Display())
+		}
+		color.Cyan("Поля: 1. Добавить  2. Изменить  3. Удалить  Enter — готово")
+
+		switch input.Line("Выберите: ") {
+		case "1":
+			if f, ok := promptField(account.CustomField{}); ok {
+				fields = append(fields, f)
+				changed = true
+			}
+		case "2":
+			if i, ok := pickField(fields); ok {
+				if f, ok := promptField(fields[i]); ok {
+					fields[i] = f
+					changed = true
+				}
+			}
+		case "3":
+			if i, ok := pickField(fields); ok {
+				fields = append(fields[:i], fields[i+1:]...)
+				changed = true
+			}
+		case "":
+			return fields, changed
+		default:
+			output.PrintError("Неверный выбор")
+		}
+	}
+}
+
+// promptField запрашивает имя, тип и значение поля; Enter оставляет прежнее
+func promptField(f account.CustomField) (account.CustomField, bool) {
+	if name := input.Line(fmt.Sprintf("Имя поля [%s]: ", f.Name)); name != "" {
+		f.Name = name
+	}
+	if f.Name == "" {
+		output.PrintError("У поля должно быть имя")
+		return f, false
+	}
+
+	for i, t := range account.FieldTypes {
+		color.White("%d. %s", i+1, t)
+	}
+	var n int
+	if _, err := fmt.Sscanf(input.Line("Тип поля: "), "%d", &n); err == nil && n >= 1 && n <= len(account.FieldTypes) {
+		f.Type = account.FieldTypes[n-1]
+	}
+	if f.Type == "" {
+		f.Type = account.FieldText
+	}
+
+	var value string
+	if f.Type == account.FieldHidden {
+		value = input.Password("Значение: ")
+	} else {
+		value = input.Line(fmt.Sprintf("Значение [%s]: ", f.Value))
+	}
+	if value != "" {
+		f.Value = value
+	}
+	return f, true
+}
+
+func pickField(fields []account.CustomField) (int, bool) {
+	var n int
+	if _, err := fmt.Sscanf(input.Line("Номер поля: "), "%d", &n); err != nil || n < 1 || n > len(fields) {
+		output.PrintError("Неверный номер")
+		return 0, false
+	}
+	return n - 1, true
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"fmt"
+	"menedger_paroley/account"
+	"menedger_paroley/input"
+	"menedger_paroley/output"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// promptItemType предлагает выбрать вид записи; Enter — логин
+func promptItemType() (account.ItemType, bool) {
+	for i, t := range account.ItemTypes {
+		color.White("%d. %s", i+1, t)
+	}
+	choice := input.Line("Вид записи [1]: ")
+	if choice == "" {
+		return account.TypeLogin, true
+	}
+	var n int
+	if _, err := fmt.Sscanf(choice, "%d", &n); err != nil || n < 1 || n > len(account.ItemTypes) {
+		return "", false
+	}
+	return account.ItemTypes[n-1], true
+}
+
+// newItem запрашивает данные записи любого вида, кроме логина
+func newItem(t account.ItemType, name string) (*account.Account, error) {
+	switch t {
+	case account.TypeNote:
+		return account.NewSecureNote(name, readLines("Текст заметки (пустая строка — конец):"))
+	case account.TypeCard:
+		return account.NewCard(name, account.Card{
+			Holder: input.Line("Держатель: "),
+			Number: input.Password("Номер карты: "),
+			Expiry: input.Line("Срок действия ММ/ГГ: "),
+			CVV:    input.Password("CVV (Enter — пропустить): "),
+			PIN:    input.Password("PIN (Enter — пропустить): "),
+			Brand:  input.Line("Платёжная система: "),
+		})
+	case account.TypeIdentity:
+		return account.NewIdentity(name, account.Identity{
+			FullName:       input.Line("ФИО: "),
+			BirthDate:      input.Line("Дата рождения ДД.ММ.ГГГГ: "),
+			Document:       input.Line("Документ (паспорт, права…): "),
+			DocumentNumber: input.Password("Номер документа: "),
+			Email:          input.Line("Email: "),
+			Phone:          input.Line("Телефон: "),
+			Address:        input.Line("Адрес: "),
+		})
+	case account.TypeSSHKey:
+		key, err := readSSHKey(input.Line("Путь к закрытому ключу: "))
+		if err != nil {
+			return nil, err
+		}
+		return account.NewSSHKey(name, key, input.Password("Пароль ключа (Enter — без пароля): "))
+	case account.TypeAPI:
+		return account.NewAPICredential(name, account.APICredential{
+			Endpoint: input.Line("Адрес API: "),
+			Key:      input.Line("Ключ (ID): "),
+		}, input.Password("Секрет: "))
+	default:
+		return nil, fmt.Errorf("неизвестный вид записи %q", t)
+	}
+}
+
+// editItemData запрашивает изменения данных, зависящих от вида записи.
+// Логины правятся в editAccount по-старому. false — правка отменена из-за ошибки.
+func editItemData(acc account.Account, upd *account.AccountUpdate) bool {
+	switch acc.Kind() {
+	case account.TypeNote:
+		if input.Line("Изменить текст заметки? [д/Enter]: ") == "д" {
+			text := readLines("Новый текст (пустая строка — конец):")
+			upd.Notes = &text
+		}
+	case account.TypeCard:
+		c := account.Card{}
+		if acc.Card != nil {
+			c = *acc.Card
+		}
+		changed := changeLine(&c.Holder, "Держатель")
+		changed = changeSecret(&c.Number, "Номер карты") || changed
+		changed = changeLine(&c.Expiry, "Срок действия") || changed
+		changed = changeSecret(&c.CVV, "CVV") || changed
+		changed = changeSecret(&c.PIN, "PIN") || changed
+		changed = changeLine(&c.Brand, "Платёжная система") || changed
+		if changed {
+			upd.Card = &c
+		}
+	case account.TypeIdentity:
+		id := account.Identity{}
+		if acc.Identity != nil {
+			id = *acc.Identity
+		}
+		changed := changeLine(&id.FullName, "ФИО")
+		changed = changeLine(&id.BirthDate, "Дата рождения") || changed
+		changed = changeLine(&id.Document, "Документ") || changed
+		changed = changeSecret(&id.DocumentNumber, "Номер документа") || changed
+		changed = changeLine(&id.Email, "Email") || changed
+		changed = changeLine(&id.Phone, "Телефон") || changed
+		changed = changeLine(&id.Address, "Адрес") || changed
+		if changed {
+			upd.Identity = &id
+		}
+	case account.TypeSSHKey:
+		if path := input.Line("Путь к новому закрытому ключу (Enter — оставить): "); path != "" {
+			key, err := readSSHKey(path)
+			if err != nil {
+				output.PrintError(err)
+				return false
+			}
+			upd.SSHKey = &key
+		}
+		if pass := input.Password("Новый пароль ключа (Enter — оставить): "); pass != "" {
+			upd.Password = &pass
+		}
+	case account.TypeAPI:
+		a := account.APICredential{}
+		if acc.API != nil {
+			a = *acc.API
+		}
+		changed := changeLine(&a.Endpoint, "Адрес API")
+		changed = changeLine(&a.Key, "Ключ (ID)") || changed
+		if changed {
+			upd.API = &a
+		}
+		if secret := input.Password("Новый секрет (Enter — оставить): "); secret != "" {
+			upd.Password = &secret
+		}
+	}
+	return true
+}
+
+// changeLine запрашивает новое значение открытого поля через promptChange
+func changeLine(dst *string, label string) bool {
+	if v := promptChange(label, *dst); v != nil {
+		*dst = *v
+		return true
+	}
+	return false
+}
+
+// changeSecret запрашивает новое значение секрета без эха; Enter — оставить
+func changeSecret(dst *string, label string) bool {
+	v := input.Password(fmt.Sprintf("%s (Enter — оставить): ", label))
+	if v == "" || v == *dst {
+		return false
+	}
+	*dst = v
+	return true
+}
+
+// readLines читает строки до первой пустой и склеивает их через перевод строки
+func readLines(prompt string) string {
+	color.White(prompt)
+	var lines []string
+	for {
+		line := input.Line("")
+		if line == "" {
+			return strings.Join(lines, "\n")
+		}
+		lines = append(lines, line)
+	}
+}
+
+// readSSHKey читает закрытый ключ из файла и открытый — из соседнего .pub, если он есть
+func readSSHKey(path string) (account.SSHKey, error) {
+	private, err := os.ReadFile(path)
+	if err != nil {
+		return account.SSHKey{}, fmt.Errorf("не удалось прочитать ключ: %w", err)
+	}
+	key := account.SSHKey{PrivateKey: string(private)}
+	if public, err := os.ReadFile(path + ".pub"); err == nil {
+		key.PublicKey = strings.TrimSpace(string(public))
+	}
+	return key, nil
+}
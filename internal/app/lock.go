@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"menedger_paroley/account"
+	"menedger_paroley/crypto"
+	"menedger_paroley/input"
+	"menedger_paroley/internal/auth"
+	"menedger_paroley/output"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// DefaultIdleTimeout — время бездействия, после которого сеанс блокируется
+const DefaultIdleTimeout = 5 * time.Minute
+
+// idleLock блокирует сеанс после бездействия: затирает ключ сессии в сейфе
+type idleLock struct {
+	vault     *account.VaultWithDb
+	guard     *auth.Guard
+	timeout   time.Duration
+	timer     *time.Timer
+	unlocking bool // unlockSession уже спрашивает пароль
+	mu        sync.Mutex
+}
+
+func newIdleLock(vault *account.VaultWithDb, guard *auth.Guard, timeout time.Duration) *idleLock {
+	l := &idleLock{vault: vault, guard: guard, timeout: timeout}
+	l.touch()
+	return l
+}
+
+// touch откладывает блокировку на timeout от текущего момента
+func (l *idleLock) touch() {
+	if l.timeout <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.timer = time.AfterFunc(l.timeout, l.lock)
+}
+
+func (l *idleLock) lock() {
+	if !l.vault.HasKey() {
+		return
+	}
+	l.vault.ClearKey()
+	color.Yellow("\nСеанс заблокирован из-за бездействия")
+}
+
+// afterInput вызывается после каждого ввода внутри действия меню. Если сеанс
+// заблокировался, пока действие ждало ввода, введённое не используется,
+// пока не введён мастер-пароль: иначе любой, кто подошёл к терминалу,
+// получил бы найденный пароль или изменил запись.
+func (l *idleLock) afterInput() {
+	if l.unlocking {
+		return
+	}
+	if !l.vault.HasKey() {
+		l.unlocking = true
+		unlockSession(l.vault, l.guard)
+		l.unlocking = false
+	}
+	l.touch()
+}
+
+func (l *idleLock) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+}
+
+// unlockSession запрашивает мастер-пароль, пока он не будет принят,
+// и возвращает сейфу ключ сессии
+func unlockSession(vault *account.VaultWithDb, guard *auth.Guard) {
+	verifier := vault.Verifier()
+	for {
+		password := input.Password("Сеанс заблокирован. Введите мастер-пароль: ")
+		if input.EOF() {
+			os.Exit(1)
+		}
+
+		var key *crypto.Key
+		unlocks := auth.VerifierFunc(func(password []byte) bool {
+			k, err := verifier.Unlock(password)
+			key = k
+			return err == nil
+		})
+		err := guard.Verify(unlocks, password)
+		if err == nil {
+			vault.SetKey(key)
+			color.Green("Сеанс разблокирован")
+			return
+		}
+
+		output.PrintError(err)
+		var failed *auth.FailedAttemptError
+		if !errors.As(err, &failed) {
+			os.Exit(1)
+		}
+		time.Sleep(failed.Delay)
+	}
+}
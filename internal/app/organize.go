@@ -0,0 +1,109 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"menedger_paroley/account"
+	"menedger_paroley/input"
+	"menedger_paroley/output"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+func organizeMenu(vault *account.VaultWithDb) {
+	color.Cyan("1. Список по папкам  2. Фильтр по тегу и папке  3. Изменить теги  4. Переместить в папку")
+	switch input.Line("Выберите: ") {
+	case "1":
+		listByFolder(vault.FindAccount(""))
+	case "2":
+		listByFolder(vault.Search(promptFilter()))
+	case "3":
+		accounts, ok := selectMany(vault)
+		if !ok {
+			return
+		}
+		add := account.ParseTags(input.Line("Добавить теги через запятую: "))
+		remove := account.ParseTags(input.Line("Убрать теги через запятую: "))
+		n := vault.Retag(ids(accounts), add, remove)
+		saveVault(vault, fmt.Sprintf("Теги изменены у записей: %d", n))
+	case "4":
+		accounts, ok := selectMany(vault)
+		if !ok {
+			return
+		}
+		folder := input.Line("Папка (например, Работа/Серверы; Enter — в корень): ")
+		n := vault.Move(ids(accounts), folder)
+		saveVault(vault, fmt.Sprintf("Перемещено записей: %d", n))
+	default:
+		output.PrintError("Неверный выбор")
+	}
+}
+
+func promptFilter() account.Filter {
+	return account.Filter{
+		Query:  input.Line("Поиск (Enter — все): "),
+		Tag:    input.Line("Тег (Enter — любой): "),
+		Folder: input.Line("Папка (Enter — любая): "),
+	}
+}
+
+// listByFolder печатает аккаунты, сгруппированные по папкам
+func listByFolder(accounts []account.Account) {
+	if len(accounts) == 0 {
+		output.PrintError("Не найдено")
+		return
+	}
+	accounts = slices.Clone(accounts)
+	slices.SortStableFunc(accounts, func(a, b account.Account) int {
+		return cmp.Or(cmp.Compare(a.Folder, b.Folder), cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)))
+	})
+
+	folder := "\x00"
+	for _, a := range accounts {
+		if a.Folder != folder {
+			folder = a.Folder
+			if folder == "" {
+				color.Cyan("\n📁 (без папки)")
+			} else {
+				color.Cyan("\n📁 %s", folder)
+			}
+		}
+		line := fmt.Sprintf("  %s (%s) %s", a.Name, a.Summary(), a.PrimaryURL())
+		if len(a.Tags) > 0 {
+			line += " #" + strings.Join(a.Tags, " #")
+		}
+		color.White(line)
+	}
+}
+
+// selectMany находит записи по фильтру и даёт выбрать несколько
+func selectMany(vault *account.VaultWithDb) ([]account.Account, bool) {
+	accounts := vault.Search(promptFilter())
+	if len(accounts) == 0 {
+		output.PrintError("Не найдено")
+		return nil, false
+	}
+	for i, a := range accounts {
+		color.White("%d. %s (%s) [%s]", i+1, a.Name, a.Summary(), shortID(a.ID))
+	}
+	picked, err := parseSelection(input.Line("Номера через запятую, * — все: "), len(accounts))
+	if err != nil {
+		output.PrintError(err)
+		return nil, false
+	}
+	selected := make([]account.Account, 0, len(picked))
+	for _, i := range picked {
+		selected = append(selected, accounts[i])
+	}
+	return selected, len(selected) > 0
+}
+
+func ids(accounts []account.Account) []string {
+	out := make([]string, len(accounts))
+	for i, a := range accounts {
+		out[i] = a.ID
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"errors"
+	"menedger_paroley/account"
+	"menedger_paroley/crypto"
+	"menedger_paroley/input"
+	"menedger_paroley/internal/auth"
+	"menedger_paroley/output"
+
+	"github.com/fatih/color"
+)
+
+var ErrEmptyPassword = errors.New("мастер-пароль не может быть пустым")
+
+// ChangeMasterPassword перешифровывает сейф ключом из нового мастер-пароля.
+// Проверочное значение хранится в заголовке сейфа, поэтому сейф и проверка
+// пароля меняются одной записью и не могут разойтись.
+func ChangeMasterPassword(vault *account.VaultWithDb, guard *auth.Guard, oldPassword, newPassword string) error {
+	if err := guard.Verify(vault.Verifier(), oldPassword); err != nil {
+		return err
+	}
+	return SetMasterPassword(vault, newPassword)
+}
+
+// SetMasterPassword перешифровывает уже открытый сейф ключом из нового
+// мастер-пароля. Старый пароль не проверяется: сейф открыт им только что.
+func SetMasterPassword(vault *account.VaultWithDb, newPassword string) error {
+	if newPassword == "" {
+		return ErrEmptyPassword
+	}
+
+	key, err := crypto.DeriveKey([]byte(newPassword), crypto.DefaultParams)
+	if err != nil {
+		return err
+	}
+	return vault.Rekey(key)
+}
+
+func changeMasterPassword(vault *account.VaultWithDb, guard *auth.Guard) {
+	oldPassword := input.Password("Текущий мастер-пароль: ")
+	newPassword, err := input.NewPassword("Новый мастер-пароль: ")
+	if err != nil {
+		output.PrintError(err)
+		return
+	}
+
+	if err := ChangeMasterPassword(vault, guard, oldPassword, newPassword); err != nil {
+		output.PrintError(err)
+		return
+	}
+	color.Green("Мастер-пароль изменён")
+}
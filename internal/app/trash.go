@@ -0,0 +1,95 @@
+package app
+
+import (
+	"fmt"
+	"menedger_paroley/account"
+	"menedger_paroley/input"
+	"menedger_paroley/output"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func trashMenu(vault *account.VaultWithDb) {
+	trashed := vault.Trashed()
+	retention := vault.Data.Settings.TrashRetention()
+	if len(trashed) == 0 {
+		color.Yellow("Корзина пуста")
+	}
+	for i, a := range trashed {
+		left := time.Until(a.DeletedAt.Add(retention))
+		color.White("%d. %s (%s) %s — удалён %s, осталось дней: %d",
+			i+1, a.Name, a.Summary(), a.PrimaryURL(), a.DeletedAt.Format("02.01.2006"), int(left.Hours()/24)+1)
+	}
+
+	color.Cyan("1. Восстановить  2. Удалить навсегда  3. Очистить корзину  4. Срок хранения (%d дн.)  Enter — назад",
+		int(retention.Hours()/24))
+	switch input.Line("Выберите: ") {
+	case "1":
+		picked, ok := pickTrashed(trashed)
+		if !ok {
+			return
+		}
+		restored := 0
+		for _, a := range picked {
+			if err := vault.Restore(a.ID); err != nil {
+				output.PrintError(fmt.Errorf("%s: %w", a.Name, err))
+				continue
+			}
+			restored++
+		}
+		if restored > 0 {
+			saveVault(vault, fmt.Sprintf("Восстановлено: %d", restored))
+		}
+	case "2":
+		picked, ok := pickTrashed(trashed)
+		if !ok {
+			return
+		}
+		if input.Line(fmt.Sprintf("Удалить навсегда записей: %d? Введите «да»: ", len(picked))) != "да" {
+			color.Yellow("Отменено")
+			return
+		}
+		for _, a := range picked {
+			vault.Purge(a.ID)
+		}
+		saveVault(vault, fmt.Sprintf("Удалено навсегда: %d", len(picked)))
+	case "3":
+		if len(trashed) == 0 {
+			return
+		}
+		if input.Line(fmt.Sprintf("Очистить корзину (%d записей)? Введите «да»: ", len(trashed))) != "да" {
+			color.Yellow("Отменено")
+			return
+		}
+		for _, a := range trashed {
+			vault.Purge(a.ID)
+		}
+		saveVault(vault, "Корзина очищена")
+	case "4":
+		var days int
+		if _, err := fmt.Sscanf(input.Line("Срок хранения в днях: "), "%d", &days); err != nil || days < 1 {
+			output.PrintError("Введите число дней больше нуля")
+			return
+		}
+		vault.SetTrashRetention(days)
+		vault.PurgeExpired(time.Now())
+		saveVault(vault, "Срок хранения изменён")
+	}
+}
+
+func pickTrashed(trashed []account.Account) ([]account.Account, bool) {
+	if len(trashed) == 0 {
+		return nil, false
+	}
+	picked, err := parseSelection(input.Line("Номера через запятую, * — все: "), len(trashed))
+	if err != nil {
+		output.PrintError(err)
+		return nil, false
+	}
+	accounts := make([]account.Account, 0, len(picked))
+	for _, i := range picked {
+		accounts = append(accounts, trashed[i])
+	}
+	return accounts, len(accounts) > 0
+}
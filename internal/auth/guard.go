@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWrongPassword = errors.New("неверный мастер-пароль")
+	ErrWiped         = errors.New("превышено число неудачных попыток: сейф стёрт")
+)
+
+// LockedError — попытка входа раньше, чем истекла задержка после неудач
+type LockedError struct {
+	Until     time.Time
+	Remaining time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("слишком много неудачных попыток, повторите через %s", e.Remaining.Round(time.Second))
+}
+
+// FailedAttemptError — неверный пароль с учётом счётчика попыток
+type FailedAttemptError struct {
+	Failures     int
+	Delay        time.Duration
+	AttemptsLeft int // -1, если стирание сейфа отключено
+}
+
+func (e *FailedAttemptError) Error() string {
+	msg := ErrWrongPassword.Error()
+	if e.Delay > 0 {
+		msg += fmt.Sprintf(". Следующая попытка через %s", e.Delay.Round(time.Second))
+	}
+	if e.AttemptsLeft >= 0 {
+		msg += fmt.Sprintf(". До стирания сейфа осталось попыток: %d", e.AttemptsLeft)
+	}
+	return msg
+}
+
+func (e *FailedAttemptError) Unwrap() error {
+	return ErrWrongPassword
+}
+
+// Policy — правила защиты от подбора пароля
+type Policy struct {
+	FreeAttempts int           // неудачные попытки без задержки
+	BaseDelay    time.Duration // задержка после первой «платной» неудачи, дальше удваивается
+	MaxDelay     time.Duration
+	WipeAfter    int // стереть сейф после стольких неудач подряд; 0 — никогда
+}
+
+var DefaultPolicy = Policy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Hour,
+}
+
+// Store хранит состояние счётчика между запусками
+type Store interface {
+	Read() ([]byte, error)
+	Write([]byte) error
+}
+
+// VerifierFunc позволяет использовать функцию как Verifier
+type VerifierFunc func(password []byte) bool
+
+func (f VerifierFunc) Verify(password []byte) bool {
+	return f(password)
+}
+
+type guardState struct {
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+// Guard считает неудачные попытки входа и вводит экспоненциальную задержку
+type Guard struct {
+	store  Store
+	policy Policy
+	onWipe func() error
+	mu     sync.Mutex
+
+	// Now — источник времени; подменяется в тестах
+	Now func() time.Time
+}
+
+func NewGuard(store Store, policy Policy) *Guard {
+	return &Guard{store: store, policy: policy, Now: time.Now}
+}
+
+// OnWipe задаёт действие, которое стирает сейф после WipeAfter неудач
+func (g *Guard) OnWipe(wipe func() error) {
+	g.mu.Lock()
+	g.onWipe = wipe
+	g.mu.Unlock()
+}
+
+// Check возвращает *LockedError, если задержка после неудач ещё не истекла
+func (g *Guard) Check() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.check(g.load())
+}
+
+func (g *Guard) check(st guardState) error {
+	now := g.Now()
+	if now.Before(st.LockedUntil) {
+		return &LockedError{Until: st.LockedUntil, Remaining: st.LockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+// Verify проверяет пароль с учётом счётчика. Возвращает *LockedError,
+// *FailedAttemptError (errors.Is(err, ErrWrongPassword)) или ErrWiped.
+func (g *Guard) Verify(v Verifier, password string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	st := g.load()
+	if err := g.check(st); err != nil {
+		return err
+	}
+
+	if v != nil && v.Verify([]byte(password)) {
+		if st.Failures > 0 {
+			return g.save(guardState{})
+		}
+		return nil
+	}
+
+	st.Failures++
+	if g.policy.WipeAfter > 0 && st.Failures >= g.policy.WipeAfter && g.onWipe != nil {
+		if err := g.onWipe(); err != nil {
+			return err
+		}
+		g.save(guardState{})
+		return ErrWiped
+	}
+
+	delay := g.delay(st.Failures)
+	st.LockedUntil = g.Now().Add(delay)
+	if err := g.save(st); err != nil {
+		return err
+	}
+
+	left := -1
+	if g.policy.WipeAfter > 0 {
+		left = g.policy.WipeAfter - st.Failures
+	}
+	return &FailedAttemptError{Failures: st.Failures, Delay: delay, AttemptsLeft: left}
+}
+
+// delay — BaseDelay * 2^(n-FreeAttempts-1), но не больше MaxDelay
+func (g *Guard) delay(failures int) time.Duration {
+	n := failures - g.policy.FreeAttempts
+	if n <= 0 || g.policy.BaseDelay <= 0 {
+		return 0
+	}
+	d := g.policy.BaseDelay
+	for i := 1; i < n && i < 32; i++ {
+		if g.policy.MaxDelay > 0 && d >= g.policy.MaxDelay {
+			break
+		}
+		d *= 2
+	}
+	if g.policy.MaxDelay > 0 && d > g.policy.MaxDelay {
+		d = g.policy.MaxDelay
+	}
+	return d
+}
+
+func (g *Guard) load() guardState {
+	var st guardState
+	data, err := g.store.Read()
+	if err != nil {
+		return st
+	}
+	json.Unmarshal(data, &st)
+	return st
+}
+
+func (g *Guard) save(st guardState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return g.store.Write(data)
+}
+
+// FileStore хранит состояние счётчика в локальном файле
+type FileStore string
+
+func (f FileStore) Read() ([]byte, error) {
+	return os.ReadFile(string(f))
+}
+
+func (f FileStore) Write(data []byte) error {
+	return os.WriteFile(string(f), data, 0600)
+}
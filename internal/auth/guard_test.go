@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// memStore — Store в памяти вместо attempts.json
+type memStore struct {
+	data []byte
+}
+
+func (s *memStore) Read() ([]byte, error) {
+	if s.data == nil {
+		return nil, os.ErrNotExist
+	}
+	return s.data, nil
+}
+
+func (s *memStore) Write(data []byte) error {
+	s.data = append([]byte(nil), data...)
+	return nil
+}
+
+// fakeClock — время, которое двигается только вручную
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+const testPassword = "correct horse"
+
+var testVerifier = VerifierFunc(func(password []byte) bool {
+	return string(password) == testPassword
+})
+
+func newTestGuard(policy Policy) (*Guard, *memStore, *fakeClock) {
+	store := &memStore{}
+	clock := &fakeClock{now: time.Date(2025, 4, 5, 12, 0, 0, 0, time.UTC)}
+	g := NewGuard(store, policy)
+	g.Now = clock.Now
+	return g, store, clock
+}
+
+// fail делает неудачную попытку после того, как истекла прежняя задержка
+func fail(t *testing.T, g *Guard, clock *fakeClock) *FailedAttemptError {
+	t.Helper()
+	clock.Advance(24 * time.Hour)
+	err := g.Verify(testVerifier, "wrong")
+	var failed *FailedAttemptError
+	if !errors.As(err, &failed) {
+		t.Fatalf("Verify: ожидалась *FailedAttemptError, получено %v", err)
+	}
+	if !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("errors.Is(%v, ErrWrongPassword) = false", err)
+	}
+	return failed
+}
+
+func TestGuardDelayDoublesUpToMax(t *testing.T) {
+	g, _, clock := newTestGuard(Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 4 * time.Second})
+
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i, delay := range want {
+		failed := fail(t, g, clock)
+		if failed.Failures != i+1 {
+			t.Errorf("попытка %d: Failures = %d", i+1, failed.Failures)
+		}
+		if failed.Delay != delay {
+			t.Errorf("попытка %d: Delay = %s, ожидалось %s", i+1, failed.Delay, delay)
+		}
+		if failed.AttemptsLeft != -1 {
+			t.Errorf("попытка %d: AttemptsLeft = %d без WipeAfter", i+1, failed.AttemptsLeft)
+		}
+	}
+}
+
+func TestGuardLockedUntilDelayExpires(t *testing.T) {
+	g, _, clock := newTestGuard(Policy{FreeAttempts: 1, BaseDelay: 10 * time.Second, MaxDelay: time.Minute})
+
+	fail(t, g, clock)
+	failed := fail(t, g, clock)
+	if failed.Delay != 10*time.Second {
+		t.Fatalf("Delay = %s, ожидалось 10s", failed.Delay)
+	}
+
+	clock.Advance(4 * time.Second)
+	err := g.Verify(testVerifier, testPassword)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Verify до истечения задержки: ожидалась *LockedError, получено %v", err)
+	}
+	if locked.Remaining != 6*time.Second {
+		t.Errorf("Remaining = %s, ожидалось 6s", locked.Remaining)
+	}
+	if !errors.As(g.Check(), &locked) {
+		t.Errorf("Check до истечения задержки не вернул *LockedError")
+	}
+
+	clock.Advance(6 * time.Second)
+	if err := g.Check(); err != nil {
+		t.Fatalf("Check после задержки: %v", err)
+	}
+	if err := g.Verify(testVerifier, testPassword); err != nil {
+		t.Fatalf("Verify верного пароля после задержки: %v", err)
+	}
+}
+
+func TestGuardSuccessResetsCounter(t *testing.T) {
+	g, store, clock := newTestGuard(Policy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute})
+
+	fail(t, g, clock)
+	fail(t, g, clock)
+	clock.Advance(time.Hour)
+	if err := g.Verify(testVerifier, testPassword); err != nil {
+		t.Fatalf("Verify верного пароля: %v", err)
+	}
+	if st := g.load(); st.Failures != 0 || !st.LockedUntil.IsZero() {
+		t.Errorf("после успеха состояние %+v, ожидался сброс", st)
+	}
+	if store.data == nil {
+		t.Errorf("сброшенное состояние не записано в Store")
+	}
+
+	if failed := fail(t, g, clock); failed.Failures != 1 || failed.Delay != 0 {
+		t.Errorf("после сброса: Failures = %d, Delay = %s", failed.Failures, failed.Delay)
+	}
+}
+
+func TestGuardWipeAfter(t *testing.T) {
+	g, _, clock := newTestGuard(Policy{FreeAttempts: 5, BaseDelay: time.Second, WipeAfter: 3})
+	wiped := 0
+	g.OnWipe(func() error {
+		wiped++
+		return nil
+	})
+
+	for i := 1; i < 3; i++ {
+		failed := fail(t, g, clock)
+		if failed.AttemptsLeft != 3-i {
+			t.Errorf("попытка %d: AttemptsLeft = %d, ожидалось %d", i, failed.AttemptsLeft, 3-i)
+		}
+	}
+	if wiped != 0 {
+		t.Fatalf("сейф стёрт раньше времени")
+	}
+
+	if err := g.Verify(testVerifier, "wrong"); !errors.Is(err, ErrWiped) {
+		t.Fatalf("третья неудача: ожидалась ErrWiped, получено %v", err)
+	}
+	if wiped != 1 {
+		t.Errorf("onWipe вызван %d раз", wiped)
+	}
+	if st := g.load(); st.Failures != 0 {
+		t.Errorf("после стирания Failures = %d", st.Failures)
+	}
+}
+
+func TestGuardWipeErrorIsReturned(t *testing.T) {
+	g, _, clock := newTestGuard(Policy{WipeAfter: 1})
+	boom := errors.New("диск недоступен")
+	g.OnWipe(func() error { return boom })
+
+	clock.Advance(time.Second)
+	if err := g.Verify(testVerifier, "wrong"); !errors.Is(err, boom) {
+		t.Fatalf("ожидалась ошибка стирания, получено %v", err)
+	}
+}
@@ -1,13 +1,11 @@
 package config
 
 import (
-	"bufio"
 	"fmt"
 	"menedger_paroley/account"
 	"menedger_paroley/cloud"
 	"menedger_paroley/files"
-	"os"
-	"strings"
+	"menedger_paroley/input"
 
 	"github.com/fatih/color"
 )
@@ -31,34 +29,20 @@ func ChooseStorage() account.Db {
 }
 
 func configureCloud() account.Db {
-	url := prompt("URL: ")
-	user := prompt("Логин: ")
-	pass := PromptPassword("Пароль: ")
+	url := input.Line("URL: ")
+	user := input.Line("Логин: ")
+	pass := input.Password("Пароль: ")
 	return cloud.NewCloudDb(url, user, pass)
 }
 
-func prompt(prompt string) string {
-	fmt.Print(prompt)
-	scanner := bufio.NewReader(os.Stdin)
-	text, _ := scanner.ReadString('\n')
-	return strings.TrimSpace(text)
-}
-
 func promptInt(msg string) int {
 	for {
-		input := prompt(msg)
+		text := input.Line(msg)
 		var n int
-		_, err := fmt.Sscanf(input, "%d", &n)
+		_, err := fmt.Sscanf(text, "%d", &n)
 		if err == nil && (n == 1 || n == 2) {
 			return n
 		}
 		color.Red("Введите 1 или 2")
 	}
 }
-
-func PromptPassword(prompt string) string {
-	fmt.Print(prompt)
-	scanner := bufio.NewReader(os.Stdin)
-	password, _ := scanner.ReadString('\n')
-	return strings.TrimSpace(password)
-}
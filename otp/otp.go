@@ -0,0 +1,276 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm — хеш-функция HMAC для выработки кода
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+// Type — вид одноразовых кодов
+type Type string
+
+const (
+	TOTP  Type = "totp"  // по времени, RFC 6238
+	HOTP  Type = "hotp"  // по счётчику, RFC 4226
+	Steam Type = "steam" // Steam Guard: TOTP с 5 символами из своего алфавита
+)
+
+func (t Type) String() string {
+	switch t {
+	case "", TOTP:
+		return "TOTP"
+	case HOTP:
+		return "HOTP"
+	case Steam:
+		return "Steam Guard"
+	default:
+		return string(t)
+	}
+}
+
+const (
+	defaultDigits = 6
+	defaultPeriod = 30
+	steamDigits   = 5
+)
+
+// steamAlphabet — символы кодов Steam Guard
+const steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+var (
+	ErrInvalidSecret    = errors.New("некорректный секрет 2FA: ожидается base32")
+	ErrInvalidURI       = errors.New("некорректная ссылка otpauth://")
+	ErrUnsupportedType  = errors.New("неподдерживаемый тип одноразовых кодов")
+	ErrInvalidAlgorithm = errors.New("неподдерживаемый алгоритм: допустимы SHA1, SHA256, SHA512")
+	ErrInvalidDigits    = errors.New("длина кода должна быть 6 или 8 цифр")
+	ErrInvalidPeriod    = errors.New("период должен быть положительным")
+	ErrCounterBased     = errors.New("код HOTP выдаётся по счётчику, а не по времени")
+	ErrTimeBased        = errors.New("код выдаётся по времени, а не по счётчику")
+)
+
+// Key — параметры генератора одноразовых кодов
+type Key struct {
+	Type      Type      `json:"type,omitempty"`
+	Secret    string    `json:"secret"` // base32 без пробелов, в верхнем регистре
+	Algorithm Algorithm `json:"algorithm,omitempty"`
+	Digits    int       `json:"digits,omitempty"`
+	Period    int       `json:"period,omitempty"`  // в секундах
+	Counter   uint64    `json:"counter,omitempty"` // HOTP: счётчик для следующего кода
+	Issuer    string    `json:"issuer,omitempty"`
+	Label     string    `json:"label,omitempty"`
+}
+
+// Parse принимает ссылку otpauth://totp/..., otpauth://hotp/...,
+// steam://СЕКРЕТ или секрет TOTP в base32
+func Parse(s string) (*Key, error) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	if strings.HasPrefix(lower, "otpauth://") {
+		return parseURI(s)
+	}
+	if strings.HasPrefix(lower, "steam://") {
+		k := &Key{Type: Steam, Secret: s[len("steam://"):]}
+		if err := k.Validate(); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	k := &Key{Secret: s}
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func parseURI(s string) (*Key, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, ErrInvalidURI
+	}
+	q := u.Query()
+	k := &Key{
+		Type:      Type(strings.ToLower(u.Host)),
+		Secret:    q.Get("secret"),
+		Algorithm: Algorithm(strings.ToUpper(q.Get("algorithm"))),
+		Issuer:    q.Get("issuer"),
+		Label:     strings.TrimPrefix(u.Path, "/"),
+	}
+	if v := q.Get("digits"); v != "" {
+		if k.Digits, err = strconv.Atoi(v); err != nil {
+			return nil, ErrInvalidDigits
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		if k.Period, err = strconv.Atoi(v); err != nil {
+			return nil, ErrInvalidPeriod
+		}
+	}
+	if v := q.Get("counter"); v != "" {
+		if k.Counter, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, ErrInvalidURI
+		}
+	}
+	// Так Steam-коды помечают сторонние приложения
+	if k.Type == TOTP && (strings.EqualFold(q.Get("encoder"), "steam") || strings.EqualFold(k.Issuer, "steam")) {
+		k.Type = Steam
+	}
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Validate нормализует секрет и проверяет параметры
+func (k *Key) Validate() error {
+	k.Secret = normalizeSecret(k.Secret)
+	if _, err := k.secret(); err != nil || k.Secret == "" {
+		return ErrInvalidSecret
+	}
+	switch k.Type {
+	case "", TOTP, HOTP:
+	case Steam:
+		// Steam Guard всегда использует SHA1 и 5 символов
+		k.Algorithm, k.Digits = "", 0
+	default:
+		return ErrUnsupportedType
+	}
+	if _, err := k.Algorithm.hash(); err != nil {
+		return err
+	}
+	if k.Digits != 0 && k.Digits != 6 && k.Digits != 8 {
+		return ErrInvalidDigits
+	}
+	if k.Period < 0 {
+		return ErrInvalidPeriod
+	}
+	return nil
+}
+
+// CounterBased сообщает, что коды выдаются по счётчику (HOTP)
+func (k *Key) CounterBased() bool {
+	return k.Type == HOTP
+}
+
+// Code возвращает код для момента t и время, которое он ещё действителен
+func (k *Key) Code(t time.Time) (string, time.Duration, error) {
+	if k.CounterBased() {
+		return "", 0, ErrCounterBased
+	}
+	period := int64(k.period())
+	counter := t.Unix() / period
+	code, err := k.code(uint64(counter))
+	if err != nil {
+		return "", 0, err
+	}
+	next := time.Unix((counter+1)*period, 0)
+	return code, next.Sub(t), nil
+}
+
+// Next возвращает код HOTP для текущего счётчика и увеличивает счётчик.
+// Сохранить ключ с новым счётчиком должен вызывающий.
+func (k *Key) Next() (string, error) {
+	if !k.CounterBased() {
+		return "", ErrTimeBased
+	}
+	code, err := k.code(k.Counter)
+	if err != nil {
+		return "", err
+	}
+	k.Counter++
+	return code, nil
+}
+
+// code вычисляет код по счётчику (RFC 4226) в формате нужного вида
+func (k *Key) code(counter uint64) (string, error) {
+	secret, err := k.secret()
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+	newHash, err := k.Algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(newHash, secret)
+	mac.Write(binary.BigEndian.AppendUint64(nil, counter))
+	value := truncate(mac.Sum(nil))
+
+	if k.Type == Steam {
+		code := make([]byte, steamDigits)
+		for i := range code {
+			code[i] = steamAlphabet[value%uint32(len(steamAlphabet))]
+			value /= uint32(len(steamAlphabet))
+		}
+		return string(code), nil
+	}
+
+	digits := k.digits()
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod), nil
+}
+
+// truncate — динамическое усечение HMAC до 31-битного числа
+func truncate(sum []byte) uint32 {
+	offset := sum[len(sum)-1] & 0x0f
+	return binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+}
+
+func (k *Key) secret() ([]byte, error) {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(k.Secret)
+}
+
+func (k *Key) digits() int {
+	if k.Digits == 0 {
+		return defaultDigits
+	}
+	return k.Digits
+}
+
+func (k *Key) period() int {
+	if k.Period <= 0 {
+		return defaultPeriod
+	}
+	return k.Period
+}
+
+func (a Algorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case "", SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+}
+
+// normalizeSecret убирает пробелы, дефисы и паддинг и приводит к верхнему регистру
+func normalizeSecret(s string) string {
+	s = strings.ToUpper(s)
+	s = strings.NewReplacer(" ", "", "-", "", "=", "").Replace(s)
+	return s
+}
@@ -0,0 +1,165 @@
+package otp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// Ключи из приложений RFC 4226 и RFC 6238: ASCII-строка "1234567890",
+// повторённая до длины выхода хеша
+var (
+	rfcSecretSHA1   = b32("12345678901234567890")
+	rfcSecretSHA256 = b32("12345678901234567890123456789012")
+	rfcSecretSHA512 = b32("1234567890123456789012345678901234567890123456789012345678901234")
+)
+
+func b32(s string) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(s))
+}
+
+// RFC 4226, приложение D
+func TestHOTPRFC4226(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+	k := &Key{Type: HOTP, Secret: rfcSecretSHA1}
+	if err := k.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for i, code := range want {
+		got, err := k.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if got != code {
+			t.Errorf("счётчик %d: %s, ожидалось %s", i, got, code)
+		}
+	}
+	if k.Counter != uint64(len(want)) {
+		t.Errorf("Counter = %d, ожидалось %d", k.Counter, len(want))
+	}
+	if _, _, err := k.Code(time.Now()); err != ErrCounterBased {
+		t.Errorf("Code для HOTP: %v, ожидалась ErrCounterBased", err)
+	}
+}
+
+// RFC 6238, приложение B: 8 цифр, период 30 секунд
+func TestTOTPRFC6238(t *testing.T) {
+	tests := []struct {
+		unix                 int64
+		sha1, sha256, sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+	keys := []struct {
+		alg    Algorithm
+		secret string
+	}{
+		{SHA1, rfcSecretSHA1},
+		{SHA256, rfcSecretSHA256},
+		{SHA512, rfcSecretSHA512},
+	}
+	for _, tt := range tests {
+		for i, want := range []string{tt.sha1, tt.sha256, tt.sha512} {
+			k := &Key{Secret: keys[i].secret, Algorithm: keys[i].alg, Digits: 8}
+			if err := k.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := k.Code(time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatalf("Code: %v", err)
+			}
+			if got != want {
+				t.Errorf("%s, t=%d: %s, ожидалось %s", keys[i].alg, tt.unix, got, want)
+			}
+		}
+	}
+}
+
+// С периодом 60 секунд момент t=119 даёт тот же счётчик 1, что t=59
+// при 30 секундах, а код действителен до конца минуты
+func TestTOTPCustomPeriod(t *testing.T) {
+	k := &Key{Secret: rfcSecretSHA1, Digits: 8, Period: 60}
+	code, left, err := k.Code(time.Unix(119, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "94287082" {
+		t.Errorf("код %s, ожидалось 94287082", code)
+	}
+	if left != time.Second {
+		t.Errorf("действителен ещё %s, ожидалась 1s", left)
+	}
+	if _, left, _ = k.Code(time.Unix(120, 0)); left != time.Minute {
+		t.Errorf("в начале периода действителен %s, ожидалась 1m", left)
+	}
+}
+
+// Коды Steam Guard для секрета из RFC 6238 посчитаны отдельной реализацией
+// алгоритма Steam: усечённый HMAC-SHA1 по счётчику времени, пять символов
+// алфавита 23456789BCDFGHJKMNPQRTVWXY от младших разрядов
+func TestSteamGuard(t *testing.T) {
+	k, err := Parse("steam://" + rfcSecretSHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "PV9M4"},
+		{1111111109, "PY4YB"},
+		{1234567890, "VHHQY"},
+		{2000000000, "9N776"},
+	} {
+		got, _, err := k.Code(time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("t=%d: %s, ожидалось %s", tt.unix, got, tt.want)
+		}
+	}
+
+	k, err = Parse("otpauth://totp/Steam:alice?secret=" + rfcSecretSHA1 + "&issuer=Steam&digits=8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := k.Code(time.Unix(59, 0)); k.Type != Steam || got != "PV9M4" {
+		t.Errorf("otpauth с issuer=Steam: тип %s, код %s", k.Type, got)
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	k, err := Parse("otpauth://totp/ACME:alice?secret=" + rfcSecretSHA1 + "&algorithm=sha512&digits=8&period=60&issuer=ACME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Type != TOTP || k.Algorithm != SHA512 || k.Digits != 8 || k.Period != 60 || k.Issuer != "ACME" || k.Label != "ACME:alice" {
+		t.Errorf("разобрано %+v", k)
+	}
+
+	k, err = Parse("otpauth://hotp/alice?secret=" + rfcSecretSHA1 + "&counter=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := k.Next(); code != "254676" {
+		t.Errorf("HOTP со счётчиком 5: %s, ожидалось 254676", code)
+	}
+
+	for _, s := range []string{
+		"otpauth://totp/x?secret=" + rfcSecretSHA1 + "&digits=7",
+		"otpauth://totp/x?secret=" + rfcSecretSHA1 + "&algorithm=MD5",
+		"otpauth://foo/x?secret=" + rfcSecretSHA1,
+		"не base32!",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) не вернул ошибку", s)
+		}
+	}
+}